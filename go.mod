@@ -7,3 +7,7 @@ require github.com/joho/godotenv v1.5.1
 require github.com/golang-jwt/jwt/v5 v5.2.1
 
 require github.com/lib/pq v1.10.9
+
+require golang.org/x/crypto v0.40.0
+
+require golang.org/x/sys v0.34.0 // indirect
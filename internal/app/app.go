@@ -13,6 +13,8 @@ import (
 	"github.com/artemwebber1/friendly_reminder/internal/controller"
 	"github.com/artemwebber1/friendly_reminder/internal/reminder"
 	repo "github.com/artemwebber1/friendly_reminder/internal/repository/postgres"
+	"github.com/artemwebber1/friendly_reminder/internal/trash"
+	"github.com/artemwebber1/friendly_reminder/pkg/authorization"
 	"github.com/artemwebber1/friendly_reminder/pkg/email"
 	_ "github.com/lib/pq" // postgres driver
 )
@@ -41,6 +43,14 @@ func (a *App) Run(ctx context.Context) {
 	usersRepo := repo.NewUsersRepository(db)
 	tasksRepo := repo.NewTasksRepository(db)
 	unverifiedUsersRepo := repo.NewUnverifiedUsersRepository(db)
+	listsRepo := repo.NewListsRepository(db)
+	commentsRepo := repo.NewCommentsRepository(db)
+	sessionsRepo := repo.NewSessionsRepository(db)
+	passwordResetsRepo := repo.NewPasswordResetsRepository(db)
+	emailChangesRepo := repo.NewEmailChangesRepository(db)
+
+	// Токены завершённых сессий отклоняются при авторизации
+	authorization.SetSessionChecker(sessionsRepo)
 
 	// Объект для рассылки писем
 	emailSender := email.NewSender(
@@ -52,15 +62,24 @@ func (a *App) Run(ctx context.Context) {
 
 	// Создание контроллеров и добавление эндпоинтов
 	mux := http.NewServeMux()
-	usersController := controller.NewUsersController(usersRepo, unverifiedUsersRepo, emailSender, a.cfg)
+	usersController := controller.NewUsersController(usersRepo, unverifiedUsersRepo, sessionsRepo, passwordResetsRepo, emailChangesRepo, emailSender, a.cfg)
 	tasksController := controller.NewTasksController(tasksRepo, usersRepo, a.cfg)
+	listsController := controller.NewListsController(listsRepo, usersRepo, emailSender, a.cfg)
+	commentsController := controller.NewCommentsController(commentsRepo, usersRepo, a.cfg)
 
 	usersController.AddEndpoints(mux)
 	tasksController.AddEndpoints(mux)
+	listsController.AddEndpoints(mux)
+	commentsController.AddEndpoints(mux)
 
 	// Запуск рассыльщика
-	listSender := reminder.New(emailSender, usersRepo, tasksRepo)
+	listSender := reminder.New(emailSender, usersRepo, tasksRepo, commentsRepo)
 	go listSender.StartSending(ctx, a.cfg.ListSenderOptions.Delay*time.Second)
+	go listSender.StartReminding(ctx, a.cfg.ListSenderOptions.PollInterval*time.Second)
+
+	// Запуск очистки корзины
+	trashPurger := trash.NewPurger(tasksRepo, a.cfg.TrashOptions.Retention*time.Hour)
+	go trashPurger.StartPurging(ctx, a.cfg.TrashOptions.PurgeInterval*time.Second)
 
 	// Запуск сервера
 	addr := ":" + a.cfg.Port
@@ -29,8 +29,20 @@ type Config struct {
 	} `json:"emailOptions"`
 
 	ListSenderOptions struct {
-		Delay time.Duration `json:"delay"`
+		Delay        time.Duration `json:"delay"`
+		PollInterval time.Duration `json:"pollInterval"` // Как часто проверять, не наступило ли время напоминаний о задачах.
 	} `json:"listSenderOptions"`
+
+	AuthOptions struct {
+		AccessTTL  time.Duration `json:"accessTtl"`  // Сколько минут действует access токен.
+		RefreshTTL time.Duration `json:"refreshTtl"` // Сколько часов действует refresh токен.
+		ResetTTL   time.Duration `json:"resetTtl"`   // Сколько минут действуют ссылки для сброса пароля и смены почты.
+	} `json:"authOptions"`
+
+	TrashOptions struct {
+		Retention     time.Duration `json:"retention"`     // Сколько часов удалённые задачи хранятся в корзине.
+		PurgeInterval time.Duration `json:"purgeInterval"` // Как часто окончательно удалять задачи с истёкшим сроком хранения.
+	} `json:"trashOptions"`
 }
 
 func NewConfig(path string) *Config {
@@ -0,0 +1,216 @@
+package controller
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+	"unicode/utf8"
+
+	"github.com/artemwebber1/friendly_reminder/internal/config"
+	"github.com/artemwebber1/friendly_reminder/internal/models"
+	"github.com/artemwebber1/friendly_reminder/pkg/authorization"
+	"github.com/artemwebber1/friendly_reminder/pkg/cors"
+	"github.com/artemwebber1/friendly_reminder/pkg/logging"
+)
+
+type commentsRepository interface {
+	// AddComment добавляет комментарий пользователя comment.UserEmail к задаче comment.TaskId и возвращает его.
+	// Если такой задачи у пользователя нет или она в корзине, возвращает sql.ErrNoRows.
+	AddComment(ctx context.Context, comment models.Comment) (models.Comment, error)
+
+	// GetComments возвращает комментарии к задаче с указанным id в порядке их добавления.
+	// Если такой задачи у пользователя userEmail нет или она в корзине, возвращает sql.ErrNoRows.
+	GetComments(ctx context.Context, taskId int64, userEmail string) ([]models.Comment, error)
+
+	// DeleteComment удаляет комментарий с указанным id к задаче taskId. Удалить комментарий может только его автор.
+	// Если такого комментария у пользователя userEmail нет, возвращает sql.ErrNoRows.
+	DeleteComment(ctx context.Context, taskId, id int64, userEmail string) error
+}
+
+type CommentsController struct {
+	commentsRepo commentsRepository
+	usersRepo    usersRepository
+	cfg          *config.Config
+}
+
+func NewCommentsController(cr commentsRepository, ur usersRepository, cfg *config.Config) *CommentsController {
+	return &CommentsController{
+		commentsRepo: cr,
+		usersRepo:    ur,
+		cfg:          cfg,
+	}
+}
+
+func (c *CommentsController) AddEndpoints(mux *http.ServeMux) {
+	mux.HandleFunc(
+		"GET "+c.cfg.Prefix+"/tasks/{id}/comments",
+		logging.Middleware(cors.Middleware(authorization.Middleware(c.GetComments))),
+	)
+
+	mux.HandleFunc(
+		"POST "+c.cfg.Prefix+"/tasks/{id}/comments",
+		logging.Middleware(cors.Middleware(authorization.Middleware(c.AddComment))),
+	)
+
+	mux.HandleFunc(
+		"DELETE "+c.cfg.Prefix+"/tasks/{id}/comments/{comment}",
+		logging.Middleware(cors.Middleware(authorization.Middleware(c.DeleteComment))),
+	)
+}
+
+// GetComments возвращает комментарии к задаче в порядке их добавления.
+//
+// Обрабатывает GET запросы по пути '/tasks/{id}/comments'.
+func (c *CommentsController) GetComments(w http.ResponseWriter, r *http.Request) {
+	rawJwt := authorization.FromHeader(r.Header)
+	jwtClaims, err := authorization.GetClaims(rawJwt, jwtKey())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	email, err := jwtClaims.GetSubject()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	if !c.usersRepo.EmailExists(r.Context(), email) {
+		http.Error(w, errInvalidEmail.Error(), http.StatusForbidden)
+		return
+	}
+
+	taskId, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	comments, err := c.commentsRepo.GetComments(r.Context(), taskId, email)
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, errTaskNotFound.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeJson(w, comments)
+}
+
+// AddComment добавляет комментарий к задаче. Комментировать задачу может любой, кому она доступна,
+// в том числе участник общего списка с ролью "viewer":
+//
+//	{"text": "Позвонил, ждём ответа"}
+//
+// Обрабатывает POST запросы по пути '/tasks/{id}/comments'.
+func (c *CommentsController) AddComment(w http.ResponseWriter, r *http.Request) {
+	rawJwt := authorization.FromHeader(r.Header)
+	jwtClaims, err := authorization.GetClaims(rawJwt, jwtKey())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	email, err := jwtClaims.GetSubject()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	if !c.usersRepo.EmailExists(r.Context(), email) {
+		http.Error(w, errInvalidEmail.Error(), http.StatusForbidden)
+		return
+	}
+
+	taskId, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	type reqBody struct {
+		Text string `json:"text"`
+	}
+
+	body, err := readBody[reqBody](r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if body.Text == "" {
+		http.Error(w, "comment text can't be empty", http.StatusBadRequest)
+		return
+	}
+
+	if utf8.RuneCountInString(body.Text) > maxCommentLength {
+		http.Error(w, errCommentTooLong.Error(), http.StatusBadRequest)
+		return
+	}
+
+	comment, err := c.commentsRepo.AddComment(r.Context(), models.Comment{
+		TaskId:    taskId,
+		UserEmail: email,
+		Text:      body.Text,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, errTaskNotFound.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	writeJson(w, comment)
+}
+
+// DeleteComment удаляет комментарий к задаче. Удалить комментарий может только его автор.
+//
+// Обрабатывает DELETE запросы по пути '/tasks/{id}/comments/{comment}'.
+func (c *CommentsController) DeleteComment(w http.ResponseWriter, r *http.Request) {
+	rawJwt := authorization.FromHeader(r.Header)
+	jwtClaims, err := authorization.GetClaims(rawJwt, jwtKey())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	email, err := jwtClaims.GetSubject()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	if !c.usersRepo.EmailExists(r.Context(), email) {
+		http.Error(w, errInvalidEmail.Error(), http.StatusForbidden)
+		return
+	}
+
+	taskId, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	commentId, err := strconv.ParseInt(r.PathValue("comment"), 10, 64)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	err = c.commentsRepo.DeleteComment(r.Context(), taskId, commentId, email)
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, errCommentNotFound.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
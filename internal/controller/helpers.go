@@ -3,6 +3,7 @@ package controller
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 	"net/http"
 	"os"
@@ -11,6 +12,41 @@ import (
 var (
 	errReadingBody  = errors.New("error reading request body")
 	errInvalidEmail = errors.New("invalid email")
+	errTaskNotFound = errors.New("task not found")
+	errListNotFound = errors.New("list not found")
+	errItemNotFound = errors.New("item not found")
+
+	errInvalidRefreshToken = errors.New("invalid or expired refresh token")
+	errWrongPassword       = errors.New("wrong password")
+
+	errMemberNotFound  = errors.New("member not found")
+	errCommentNotFound = errors.New("comment not found")
+	errInvalidRole     = errors.New("invalid role: expected \"viewer\" or \"editor\"")
+
+	errInvalidPriority = errors.New("invalid priority: expected -1 (low), 0 (normal) or 1 (high)")
+	errNotesTooLong    = fmt.Errorf("notes can't be longer than %d characters", maxNotesLength)
+	errCommentTooLong  = fmt.Errorf("comment can't be longer than %d characters", maxCommentLength)
+)
+
+// maxNotesLength - максимальная длина описания задачи в символах.
+const maxNotesLength = 10000
+
+// maxCommentLength - максимальная длина комментария к задаче в символах.
+const maxCommentLength = 2000
+
+// Размер страницы результатов поиска по умолчанию и максимальный.
+const (
+	defaultSearchLimit = 20
+	maxSearchLimit     = 100
+)
+
+// maxBatchSize - максимальное количество операций в одном пакетном запросе.
+const maxBatchSize = 100
+
+// Размер страницы списка задач по умолчанию и максимальный.
+const (
+	defaultListLimit = 50
+	maxListLimit     = 200
 )
 
 func jwtKey() []byte {
@@ -30,6 +66,24 @@ func readBody[T any](body io.ReadCloser) (*T, error) {
 	return &t, nil
 }
 
+// preflight отвечает на предварительные CORS запросы (OPTIONS). Пути, зарегистрированные только для конкретных методов
+// (например, 'PATCH /tasks/{id}'), ServeMux на OPTIONS запросы не передаёт, поэтому для них регистрируется отдельный путь
+// с этим обработчиком, обёрнутым в cors.Middleware.
+func preflight(w http.ResponseWriter, r *http.Request) {}
+
+// byMethod возвращает обработчик, который передаёт запрос обработчику, соответствующему HTTP методу запроса.
+// Нужен для путей вроде '/lists/{id}', которые обрабатывают несколько методов.
+func byMethod(handlers map[string]http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		h, ok := handlers[r.Method]
+		if !ok {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		h(w, r)
+	}
+}
+
 func writeJson[T any](w http.ResponseWriter, obj T) {
 	b, err := json.Marshal(obj)
 	if err != nil {
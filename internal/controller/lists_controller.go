@@ -0,0 +1,505 @@
+package controller
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/artemwebber1/friendly_reminder/internal/config"
+	"github.com/artemwebber1/friendly_reminder/internal/models"
+	"github.com/artemwebber1/friendly_reminder/pkg/authorization"
+	"github.com/artemwebber1/friendly_reminder/pkg/cors"
+	"github.com/artemwebber1/friendly_reminder/pkg/email"
+	"github.com/artemwebber1/friendly_reminder/pkg/logging"
+)
+
+type listsRepository interface {
+	// AddList создаёт новый список пользователя list.UserEmail. Возвращает id созданного списка.
+	AddList(ctx context.Context, list models.List) (int64, error)
+
+	// GetLists возвращает все списки пользователя с указанным email и общие списки, в которые он вступил.
+	GetLists(ctx context.Context, userEmail string) ([]models.List, error)
+
+	// UpdateList частично изменяет список с указанным id, принадлежащий пользователю userEmail, и возвращает его новое состояние.
+	// Участник общего списка может только включить или выключить рассылку задач из него для себя.
+	// Если такого списка у пользователя нет, возвращает sql.ErrNoRows.
+	UpdateList(ctx context.Context, id int64, userEmail string, patch models.ListPatch) (models.List, error)
+
+	// DeleteList удаляет список с указанным id, принадлежащий пользователю userEmail, и перемещает его задачи в корзину.
+	// Если такого списка у пользователя нет, возвращает sql.ErrNoRows.
+	DeleteList(ctx context.Context, id int64, userEmail string) error
+
+	// InviteMember приглашает пользователя member.UserEmail в список member.ListId, принадлежащий пользователю ownerEmail,
+	// с ролью member.Role и возвращает этот список. Если пользователь уже приглашён, меняет его роль.
+	// Если такого списка у владельца нет, возвращает sql.ErrNoRows.
+	InviteMember(ctx context.Context, ownerEmail string, member models.ListMember) (models.List, error)
+
+	// AcceptInvite принимает приглашение пользователя userEmail в список с указанным id и возвращает этот список.
+	// Если пользователя не приглашали в такой список, возвращает sql.ErrNoRows.
+	AcceptInvite(ctx context.Context, id int64, userEmail string) (models.List, error)
+
+	// GetMembers возвращает участников списка с указанным id, включая ещё не принявших приглашение.
+	// Если список недоступен пользователю userEmail, возвращает sql.ErrNoRows.
+	GetMembers(ctx context.Context, id int64, userEmail string) ([]models.ListMember, error)
+
+	// RemoveMember исключает пользователя memberEmail из списка с указанным id.
+	// Исключать участников может владелец списка, а выйти из списка - сам участник.
+	// Если такого участника нет или у пользователя userEmail нет прав, возвращает sql.ErrNoRows.
+	RemoveMember(ctx context.Context, id int64, memberEmail, userEmail string) error
+}
+
+type ListsController struct {
+	listsRepo   listsRepository
+	usersRepo   usersRepository
+	emailSender email.Sender
+	cfg         *config.Config
+}
+
+func NewListsController(lr listsRepository, ur usersRepository, emailSender email.Sender, cfg *config.Config) *ListsController {
+	return &ListsController{
+		listsRepo:   lr,
+		usersRepo:   ur,
+		emailSender: emailSender,
+		cfg:         cfg,
+	}
+}
+
+func (c *ListsController) AddEndpoints(mux *http.ServeMux) {
+	mux.HandleFunc(
+		c.cfg.Prefix+"/lists/new",
+		logging.Middleware(cors.Middleware(authorization.Middleware(c.CreateList))),
+	)
+
+	mux.HandleFunc(
+		c.cfg.Prefix+"/lists",
+		logging.Middleware(cors.Middleware(authorization.Middleware(c.GetLists))),
+	)
+
+	mux.HandleFunc(
+		c.cfg.Prefix+"/lists/{id}",
+		logging.Middleware(cors.Middleware(authorization.Middleware(byMethod(map[string]http.HandlerFunc{
+			http.MethodPatch:  c.UpdateList,
+			http.MethodDelete: c.DeleteList,
+		})))),
+	)
+
+	mux.HandleFunc(
+		c.cfg.Prefix+"/lists/{id}/members",
+		logging.Middleware(cors.Middleware(authorization.Middleware(byMethod(map[string]http.HandlerFunc{
+			http.MethodGet:  c.GetMembers,
+			http.MethodPost: c.InviteMember,
+		})))),
+	)
+
+	mux.HandleFunc(
+		c.cfg.Prefix+"/lists/{id}/members/{email}",
+		logging.Middleware(cors.Middleware(authorization.Middleware(c.RemoveMember))),
+	)
+
+	mux.HandleFunc(
+		c.cfg.Prefix+"/lists/{id}/join",
+		logging.Middleware(cors.Middleware(authorization.Middleware(c.JoinList))),
+	)
+}
+
+// CreateList создаёт новый список пользователя.
+// По умолчанию задачи из нового списка присылаются в рассылке.
+//
+// Обрабатывает POST запросы по пути '/lists/new'.
+func (c *ListsController) CreateList(w http.ResponseWriter, r *http.Request) {
+	rawJwt := authorization.FromHeader(r.Header)
+	jwtClaims, err := authorization.GetClaims(rawJwt, jwtKey())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	email, err := jwtClaims.GetSubject()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	if !c.usersRepo.EmailExists(r.Context(), email) {
+		http.Error(w, errInvalidEmail.Error(), http.StatusForbidden)
+		return
+	}
+
+	type newList struct {
+		Name   string `json:"name"`
+		Digest *bool  `json:"digest"`
+	}
+
+	body, err := readBody[newList](r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if body.Name == "" {
+		http.Error(w, "list name can't be empty", http.StatusBadRequest)
+		return
+	}
+
+	list := models.List{
+		UserEmail: email,
+		Name:      body.Name,
+		Digest:    body.Digest == nil || *body.Digest,
+		Role:      models.RoleOwner,
+	}
+
+	list.Id, err = c.listsRepo.AddList(r.Context(), list)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	writeJson(w, list)
+}
+
+// GetLists возвращает все списки пользователя и общие списки, в которые он вступил.
+// Поле role каждого списка содержит роль пользователя в нём.
+//
+// Обрабатывает GET запросы по пути '/lists'.
+func (c *ListsController) GetLists(w http.ResponseWriter, r *http.Request) {
+	rawJwt := authorization.FromHeader(r.Header)
+	jwtClaims, err := authorization.GetClaims(rawJwt, jwtKey())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	email, err := jwtClaims.GetSubject()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	if !c.usersRepo.EmailExists(r.Context(), email) {
+		http.Error(w, errInvalidEmail.Error(), http.StatusForbidden)
+		return
+	}
+
+	lists, err := c.listsRepo.GetLists(r.Context(), email)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeJson(w, lists)
+}
+
+// UpdateList переименовывает список и (или) включает и выключает рассылку задач из него:
+//
+//	{"name": "Работа", "digest": false}
+//
+// Участник общего списка может изменить только 'digest': рассылка включается и выключается для него одного.
+//
+// Обрабатывает PATCH запросы по пути '/lists/{id}'.
+func (c *ListsController) UpdateList(w http.ResponseWriter, r *http.Request) {
+	rawJwt := authorization.FromHeader(r.Header)
+	jwtClaims, err := authorization.GetClaims(rawJwt, jwtKey())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	email, err := jwtClaims.GetSubject()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	if !c.usersRepo.EmailExists(r.Context(), email) {
+		http.Error(w, errInvalidEmail.Error(), http.StatusForbidden)
+		return
+	}
+
+	listId, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	patch, err := readBody[models.ListPatch](r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if patch.Name == nil && patch.Digest == nil {
+		http.Error(w, "nothing to update", http.StatusBadRequest)
+		return
+	}
+
+	if patch.Name != nil && *patch.Name == "" {
+		http.Error(w, "list name can't be empty", http.StatusBadRequest)
+		return
+	}
+
+	list, err := c.listsRepo.UpdateList(r.Context(), listId, email, *patch)
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, errListNotFound.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeJson(w, list)
+}
+
+// DeleteList удаляет список пользователя. Задачи из него перемещаются в корзину.
+//
+// Обрабатывает DELETE запросы по пути '/lists/{id}'.
+func (c *ListsController) DeleteList(w http.ResponseWriter, r *http.Request) {
+	rawJwt := authorization.FromHeader(r.Header)
+	jwtClaims, err := authorization.GetClaims(rawJwt, jwtKey())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	email, err := jwtClaims.GetSubject()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	if !c.usersRepo.EmailExists(r.Context(), email) {
+		http.Error(w, errInvalidEmail.Error(), http.StatusForbidden)
+		return
+	}
+
+	listId, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	err = c.listsRepo.DeleteList(r.Context(), listId, email)
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, errListNotFound.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// InviteMember приглашает в список пользователя с ролью "viewer" (только просмотр) или "editor" (изменение задач)
+// и отправляет ему на почту ссылку для вступления в список:
+//
+//	{"email": "friend@mail.com", "role": "editor"}
+//
+// Повторное приглашение меняет роль участника. Приглашать участников может только владелец списка.
+//
+// Обрабатывает POST запросы по пути '/lists/{id}/members'.
+func (c *ListsController) InviteMember(w http.ResponseWriter, r *http.Request) {
+	rawJwt := authorization.FromHeader(r.Header)
+	jwtClaims, err := authorization.GetClaims(rawJwt, jwtKey())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	email, err := jwtClaims.GetSubject()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	if !c.usersRepo.EmailExists(r.Context(), email) {
+		http.Error(w, errInvalidEmail.Error(), http.StatusForbidden)
+		return
+	}
+
+	listId, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	type invite struct {
+		Email string          `json:"email"`
+		Role  models.ListRole `json:"role"`
+	}
+
+	inv, err := readBody[invite](r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if !inv.Role.Valid() {
+		http.Error(w, errInvalidRole.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if inv.Email == email || !c.usersRepo.EmailExists(r.Context(), inv.Email) {
+		http.Error(w, errInvalidEmail.Error(), http.StatusBadRequest)
+		return
+	}
+
+	member := models.ListMember{
+		ListId:    listId,
+		UserEmail: inv.Email,
+		Role:      inv.Role,
+	}
+
+	list, err := c.listsRepo.InviteMember(r.Context(), email, member)
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, errListNotFound.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Ссылка для вступления в список
+	joinLink := fmt.Sprintf("%s:%s%s/lists/%d/join", c.cfg.Host, c.cfg.Port, c.cfg.Prefix, list.Id)
+
+	log.Printf("Sending a list invitation to '%s'...\n", member.UserEmail)
+
+	const subject = "Friendly reminder"
+	body := fmt.Sprintf(
+		"Пользователь %s приглашает вас в список \"%s\". Чтобы вступить в список, отправьте POST запрос по ссылке:\n%s\n\nЕсли вы не знаете этого пользователя, проигнорируйте это письмо.",
+		email, list.Name, joinLink)
+
+	go c.emailSender.Send(
+		subject,
+		body,
+		member.UserEmail)
+
+	w.WriteHeader(http.StatusCreated)
+	writeJson(w, member)
+}
+
+// JoinList принимает приглашение пользователя в список. После этого задачи списка появляются в списке дел пользователя.
+//
+// Обрабатывает POST запросы по пути '/lists/{id}/join'.
+func (c *ListsController) JoinList(w http.ResponseWriter, r *http.Request) {
+	rawJwt := authorization.FromHeader(r.Header)
+	jwtClaims, err := authorization.GetClaims(rawJwt, jwtKey())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	email, err := jwtClaims.GetSubject()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	if !c.usersRepo.EmailExists(r.Context(), email) {
+		http.Error(w, errInvalidEmail.Error(), http.StatusForbidden)
+		return
+	}
+
+	listId, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	list, err := c.listsRepo.AcceptInvite(r.Context(), listId, email)
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, errListNotFound.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeJson(w, list)
+}
+
+// GetMembers возвращает участников списка. Список участников доступен владельцу и вступившим в список пользователям.
+//
+// Обрабатывает GET запросы по пути '/lists/{id}/members'.
+func (c *ListsController) GetMembers(w http.ResponseWriter, r *http.Request) {
+	rawJwt := authorization.FromHeader(r.Header)
+	jwtClaims, err := authorization.GetClaims(rawJwt, jwtKey())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	email, err := jwtClaims.GetSubject()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	if !c.usersRepo.EmailExists(r.Context(), email) {
+		http.Error(w, errInvalidEmail.Error(), http.StatusForbidden)
+		return
+	}
+
+	listId, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	members, err := c.listsRepo.GetMembers(r.Context(), listId, email)
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, errListNotFound.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeJson(w, members)
+}
+
+// RemoveMember исключает участника из списка или отменяет приглашение. Участник может удалить сам себя, чтобы выйти из списка.
+//
+// Обрабатывает DELETE запросы по пути '/lists/{id}/members/{email}'.
+func (c *ListsController) RemoveMember(w http.ResponseWriter, r *http.Request) {
+	rawJwt := authorization.FromHeader(r.Header)
+	jwtClaims, err := authorization.GetClaims(rawJwt, jwtKey())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	email, err := jwtClaims.GetSubject()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	if !c.usersRepo.EmailExists(r.Context(), email) {
+		http.Error(w, errInvalidEmail.Error(), http.StatusForbidden)
+		return
+	}
+
+	listId, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	err = c.listsRepo.RemoveMember(r.Context(), listId, r.PathValue("email"), email)
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, errMemberNotFound.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
@@ -2,28 +2,103 @@ package controller
 
 import (
 	"context"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
 
 	"github.com/artemwebber1/friendly_reminder/internal/config"
 	"github.com/artemwebber1/friendly_reminder/internal/models"
 	"github.com/artemwebber1/friendly_reminder/pkg/authorization"
 	"github.com/artemwebber1/friendly_reminder/pkg/cors"
 	"github.com/artemwebber1/friendly_reminder/pkg/logging"
+	"github.com/artemwebber1/friendly_reminder/pkg/rrule"
 )
 
 type tasksRepository interface {
-	// AddItem добавляет новую задачу в список пользователя. Возвращает id созданной задачи.
-	AddTask(ctx context.Context, value, userEmail string) (int64, error)
+	// AddTask добавляет новую задачу в список пользователя task.UserEmail и возвращает её в том виде, в котором она сохранена.
+	// Если указан task.ListId, а такого списка у пользователя нет, возвращает sql.ErrNoRows.
+	AddTask(ctx context.Context, task models.Task) (models.Task, error)
 
-	// DeleteTask удаляет задачу по указанному id.
-	DeleteTask(ctx context.Context, id int64) error
+	// DeleteTask перемещает в корзину задачу с указанным id, принадлежащую пользователю userEmail.
+	// Если такой задачи у пользователя нет, возвращает sql.ErrNoRows.
+	DeleteTask(ctx context.Context, id int64, userEmail string) error
 
 	// GetList возвращает список дел пользователя с указанным email.
-	GetList(ctx context.Context, userEmail string) ([]models.Task, error)
+	// Параметр opts позволяет отфильтровать задачи по сроку выполнения и отсортировать их.
+	GetList(ctx context.Context, userEmail string, opts models.ListOptions) ([]models.Task, error)
 
-	// ClearList очищает список указанного пользователя.
+	// Search ищет задачи пользователя userEmail по тексту и описанию. Результаты упорядочены по релевантности.
+	Search(ctx context.Context, userEmail string, q models.SearchQuery) (models.SearchResult, error)
+
+	// UpdateTask частично изменяет задачу с указанным id, принадлежащую пользователю userEmail, и возвращает её новое состояние.
+	// Если такой задачи у пользователя нет, возвращает sql.ErrNoRows.
+	UpdateTask(ctx context.Context, id int64, userEmail string, patch models.TaskPatch) (models.Task, error)
+
+	// SetCompleted отмечает задачу с указанным id, принадлежащую пользователю userEmail, как выполненную (completed = true)
+	// или снова невыполненную (completed = false). Если такой задачи у пользователя нет, возвращает sql.ErrNoRows.
+	//
+	// Если выполнена повторяющаяся задача, создаётся её следующее повторение, которое и возвращается.
+	// В остальных случаях возвращается nil.
+	SetCompleted(ctx context.Context, id int64, userEmail string, completed bool) (*models.Task, error)
+
+	// Batch выполняет операции ops над задачами пользователя userEmail в одной транзакции и возвращает результат каждой из них.
+	// Если atomic = true, при первой ошибке все изменения отменяются. Второе возвращаемое значение равно true, если изменения сохранены.
+	Batch(ctx context.Context, userEmail string, ops []models.BatchOperation, atomic bool) ([]models.BatchResult, bool, error)
+
+	// MoveTask переставляет задачу с указанным id, которую пользователь userEmail может изменять, непосредственно перед задачей anchorId
+	// (или сразу после неё, если after = true). Обе задачи должны быть в одном списке, иначе возвращается sql.ErrNoRows.
+	MoveTask(ctx context.Context, id int64, userEmail string, anchorId int64, after bool) error
+
+	// AddTags добавляет метки задаче с указанным id, принадлежащей пользователю userEmail. Уже существующие метки пропускаются.
+	// Если такой задачи у пользователя нет, возвращает sql.ErrNoRows.
+	AddTags(ctx context.Context, id int64, userEmail string, tags []string) error
+
+	// RemoveTag удаляет метку у задачи с указанным id, принадлежащей пользователю userEmail.
+	// Если такой задачи у пользователя нет, возвращает sql.ErrNoRows.
+	RemoveTag(ctx context.Context, id int64, userEmail, tag string) error
+
+	// GetItems возвращает пункты чек-листа задачи с указанным id, принадлежащей пользователю userEmail.
+	// Если такой задачи у пользователя нет, возвращает sql.ErrNoRows.
+	GetItems(ctx context.Context, taskId int64, userEmail string) ([]models.TaskItem, error)
+
+	// AddItem добавляет пункт в конец чек-листа задачи с указанным id, принадлежащей пользователю userEmail, и возвращает его.
+	// Если такой задачи у пользователя нет, возвращает sql.ErrNoRows.
+	AddItem(ctx context.Context, taskId int64, userEmail, value string) (models.TaskItem, error)
+
+	// UpdateItem частично изменяет пункт itemId чек-листа задачи taskId, принадлежащей пользователю userEmail, и возвращает его новое состояние.
+	// Если такого пункта у задачи пользователя нет, возвращает sql.ErrNoRows.
+	UpdateItem(ctx context.Context, taskId, itemId int64, userEmail string, patch models.TaskItemPatch) (models.TaskItem, error)
+
+	// DeleteItem удаляет пункт itemId из чек-листа задачи taskId, принадлежащей пользователю userEmail.
+	// Если такого пункта у задачи пользователя нет, возвращает sql.ErrNoRows.
+	DeleteItem(ctx context.Context, taskId, itemId int64, userEmail string) error
+
+	// ClearList перемещает в корзину все задачи указанного пользователя.
 	ClearList(ctx context.Context, userEmail string) error
+
+	// GetHistory возвращает историю изменений задачи с указанным id, принадлежащей пользователю userEmail, в хронологическом порядке.
+	// Если такой задачи у пользователя нет, возвращает sql.ErrNoRows.
+	GetHistory(ctx context.Context, id int64, userEmail string) ([]models.TaskEvent, error)
+
+	// GetTrash возвращает находящиеся в корзине задачи, которые пользователь userEmail может изменять,
+	// в том числе удалённые другими участниками общих списков. Недавно удалённые задачи идут первыми.
+	GetTrash(ctx context.Context, userEmail string) ([]models.Task, error)
+
+	// RestoreTask возвращает из корзины задачу с указанным id, которую пользователь userEmail может изменять.
+	// Если такой задачи в корзине пользователя нет, возвращает sql.ErrNoRows.
+	RestoreTask(ctx context.Context, id int64, userEmail string) error
+
+	// UndoDelete отменяет последнее удаление, сделанное пользователем userEmail: возвращает из корзины задачу, удалённую последней,
+	// или все задачи, если последним был очищен весь список или удалён список. Возвращает количество восстановленных задач.
+	UndoDelete(ctx context.Context, userEmail string) (int64, error)
 }
 
 type TasksController struct {
@@ -42,24 +117,104 @@ func NewTasksController(tr tasksRepository, ur usersRepository, cfg *config.Conf
 
 func (c *TasksController) AddEndpoints(mux *http.ServeMux) {
 	mux.HandleFunc(
-		c.cfg.Prefix+"/tasks/new",
+		"POST "+c.cfg.Prefix+"/tasks/new",
 		logging.Middleware(cors.Middleware(authorization.Middleware(c.CreateTask))),
 	)
 
 	mux.HandleFunc(
-		c.cfg.Prefix+"/tasks/list",
+		"GET "+c.cfg.Prefix+"/tasks/list",
 		logging.Middleware(cors.Middleware(authorization.Middleware(c.GetList))),
 	)
 
 	mux.HandleFunc(
-		c.cfg.Prefix+"/tasks/clear-list",
+		"POST "+c.cfg.Prefix+"/tasks/batch",
+		logging.Middleware(cors.Middleware(authorization.Middleware(c.Batch))),
+	)
+
+	mux.HandleFunc(
+		"GET "+c.cfg.Prefix+"/tasks/search",
+		logging.Middleware(cors.Middleware(authorization.Middleware(c.Search))),
+	)
+
+	mux.HandleFunc(
+		"DELETE "+c.cfg.Prefix+"/tasks/clear-list",
 		logging.Middleware(cors.Middleware(authorization.Middleware(c.ClearList))),
 	)
 
 	mux.HandleFunc(
-		c.cfg.Prefix+"/tasks/del/{id}",
+		"GET "+c.cfg.Prefix+"/tasks/trash",
+		logging.Middleware(cors.Middleware(authorization.Middleware(c.GetTrash))),
+	)
+
+	mux.HandleFunc(
+		"POST "+c.cfg.Prefix+"/tasks/undo",
+		logging.Middleware(cors.Middleware(authorization.Middleware(c.UndoDelete))),
+	)
+
+	mux.HandleFunc(
+		"POST "+c.cfg.Prefix+"/tasks/reorder",
+		logging.Middleware(cors.Middleware(authorization.Middleware(c.ReorderTask))),
+	)
+
+	mux.HandleFunc(
+		"DELETE "+c.cfg.Prefix+"/tasks/del/{id}",
 		logging.Middleware(cors.Middleware(authorization.Middleware(c.DeleteTask))),
 	)
+
+	mux.HandleFunc(
+		"PATCH "+c.cfg.Prefix+"/tasks/complete/{id}",
+		logging.Middleware(cors.Middleware(authorization.Middleware(c.CompleteTask))),
+	)
+
+	mux.HandleFunc(
+		"PATCH "+c.cfg.Prefix+"/tasks/{id}",
+		logging.Middleware(cors.Middleware(authorization.Middleware(c.UpdateTask))),
+	)
+
+	mux.HandleFunc(
+		"POST "+c.cfg.Prefix+"/tasks/{id}/restore",
+		logging.Middleware(cors.Middleware(authorization.Middleware(c.RestoreTask))),
+	)
+
+	mux.HandleFunc(
+		"POST "+c.cfg.Prefix+"/tasks/{id}/tags",
+		logging.Middleware(cors.Middleware(authorization.Middleware(c.AddTags))),
+	)
+
+	mux.HandleFunc(
+		"DELETE "+c.cfg.Prefix+"/tasks/{id}/tags/{tag}",
+		logging.Middleware(cors.Middleware(authorization.Middleware(c.RemoveTag))),
+	)
+
+	mux.HandleFunc(
+		"GET "+c.cfg.Prefix+"/tasks/{id}/history",
+		logging.Middleware(cors.Middleware(authorization.Middleware(c.GetHistory))),
+	)
+
+	mux.HandleFunc(
+		"GET "+c.cfg.Prefix+"/tasks/{id}/items",
+		logging.Middleware(cors.Middleware(authorization.Middleware(c.GetItems))),
+	)
+
+	mux.HandleFunc(
+		"POST "+c.cfg.Prefix+"/tasks/{id}/items",
+		logging.Middleware(cors.Middleware(authorization.Middleware(c.AddItem))),
+	)
+
+	mux.HandleFunc(
+		"PATCH "+c.cfg.Prefix+"/tasks/{id}/items/{item}",
+		logging.Middleware(cors.Middleware(authorization.Middleware(c.UpdateItem))),
+	)
+
+	mux.HandleFunc(
+		"DELETE "+c.cfg.Prefix+"/tasks/{id}/items/{item}",
+		logging.Middleware(cors.Middleware(authorization.Middleware(c.DeleteItem))),
+	)
+
+	mux.HandleFunc(
+		"OPTIONS "+c.cfg.Prefix+"/tasks/",
+		logging.Middleware(cors.Middleware(preflight)),
+	)
 }
 
 // CreateTask создаёт новую задачу в списке пользователя.
@@ -85,8 +240,13 @@ func (c *TasksController) CreateTask(w http.ResponseWriter, r *http.Request) {
 	}
 
 	type newTask struct {
-		Id    int64  `json:"task_id"`
-		Value string `json:"value"`
+		ListId     *int64          `json:"list_id,omitempty"`
+		Value      string          `json:"value"`
+		Notes      string          `json:"notes,omitempty"`
+		DueDate    *time.Time      `json:"due_date,omitempty"`
+		RemindAt   *time.Time      `json:"remind_at,omitempty"`
+		Priority   models.Priority `json:"priority"`
+		Recurrence string          `json:"recurrence,omitempty"`
 	}
 
 	task, err := readBody[newTask](r.Body)
@@ -95,21 +255,156 @@ func (c *TasksController) CreateTask(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	id, err := c.tasksRepo.AddTask(r.Context(), task.Value, email)
+	if err = validateTask(task.Priority, task.Notes, task.Recurrence); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	created, err := c.tasksRepo.AddTask(r.Context(), models.Task{
+		UserEmail:  email,
+		ListId:     task.ListId,
+		Value:      task.Value,
+		Notes:      task.Notes,
+		DueDate:    task.DueDate,
+		RemindAt:   task.RemindAt,
+		Priority:   task.Priority,
+		Recurrence: task.Recurrence,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, errListNotFound.Error(), http.StatusNotFound)
+		return
+	}
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	task.Id = id
-
 	w.WriteHeader(http.StatusCreated)
-	writeJson(w, task)
+	writeJson(w, created)
+}
+
+// Batch выполняет несколько операций над задачами пользователя в одной транзакции:
+//
+//	{
+//		"atomic": true,
+//		"operations": [
+//			{"op": "create", "task": {"value": "Купить молоко"}},
+//			{"op": "complete", "task_id": 5},
+//			{"op": "delete", "task_id": 7}
+//		]
+//	}
+//
+// Поддерживаются операции 'create' (поля задачи те же, что и в '/tasks/new'), 'complete' (с необязательным полем 'done')
+// и 'delete'. В ответе для каждой операции возвращается код состояния, созданная задача (для 'complete' - следующее повторение)
+// или ошибка:
+//
+//	{"committed": true, "results": [{"status": 201, "task": {...}}, {"status": 200}, {"status": 404, "error": "task not found"}]}
+//
+// Если 'atomic' равен true, при первой неудачной операции все изменения отменяются, а запрос завершается с кодом 409.
+// Все остальные операции, в том числе выполненные до неудачной, получают код 424. Иначе отменяются только неудачные операции.
+//
+// Обрабатывает POST запросы по пути '/tasks/batch'.
+func (c *TasksController) Batch(w http.ResponseWriter, r *http.Request) {
+	rawJwt := authorization.FromHeader(r.Header)
+	jwtClaims, err := authorization.GetClaims(rawJwt, jwtKey())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	email, err := jwtClaims.GetSubject()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	if !c.usersRepo.EmailExists(r.Context(), email) {
+		http.Error(w, errInvalidEmail.Error(), http.StatusForbidden)
+		return
+	}
+
+	type reqBody struct {
+		Atomic     bool                    `json:"atomic"`
+		Operations []models.BatchOperation `json:"operations"`
+	}
+
+	body, err := readBody[reqBody](r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if len(body.Operations) == 0 || len(body.Operations) > maxBatchSize {
+		http.Error(w, fmt.Sprintf("expected 1-%d operations", maxBatchSize), http.StatusBadRequest)
+		return
+	}
+
+	for i, op := range body.Operations {
+		if err = validateBatchOperation(op); err != nil {
+			http.Error(w, fmt.Sprintf("operation %d: %s", i, err), http.StatusBadRequest)
+			return
+		}
+	}
+
+	results, committed, err := c.tasksRepo.Batch(r.Context(), email, body.Operations, body.Atomic)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	type opResult struct {
+		Status int          `json:"status"`
+		Task   *models.Task `json:"task,omitempty"`
+		Error  string       `json:"error,omitempty"`
+	}
+
+	type resBody struct {
+		Committed bool       `json:"committed"`
+		Results   []opResult `json:"results"`
+	}
+
+	res := resBody{Committed: committed, Results: make([]opResult, len(results))}
+	for i, result := range results {
+		op := body.Operations[i]
+		switch {
+		// Если изменения не сохранены, не применена ни одна операция - ни выполненные до ошибки, ни следующие за ней
+		case !committed && result.Err == nil:
+			res.Results[i] = opResult{Status: http.StatusFailedDependency, Error: "not applied"}
+		case errors.Is(result.Err, sql.ErrNoRows) && op.Op == models.BatchCreate:
+			res.Results[i] = opResult{Status: http.StatusNotFound, Error: errListNotFound.Error()}
+		case errors.Is(result.Err, sql.ErrNoRows):
+			res.Results[i] = opResult{Status: http.StatusNotFound, Error: errTaskNotFound.Error()}
+		case result.Err != nil:
+			res.Results[i] = opResult{Status: http.StatusInternalServerError, Error: result.Err.Error()}
+		case result.Task != nil:
+			res.Results[i] = opResult{Status: http.StatusCreated, Task: result.Task}
+		default:
+			res.Results[i] = opResult{Status: http.StatusOK}
+		}
+	}
+
+	if !committed {
+		w.WriteHeader(http.StatusConflict)
+	}
+	writeJson(w, res)
 }
 
-// GetList получает список пользователя.
+// GetList получает список пользователя постранично:
+//
+//	{"tasks": [...], "next_cursor": "eyJzIjoi..."}
 //
 // Обрабатывает GET запросы по пути '/tasks/list'.
+// Параметр 'limit' задаёт размер страницы (по умолчанию 50, не больше 200). Чтобы получить следующую страницу,
+// значение 'next_cursor' из ответа передаётся в параметре 'cursor' вместе с теми же параметрами сортировки и фильтров.
+// На последней странице 'next_cursor' отсутствует.
+//
+// Необязательный параметр 'due' (overdue, today, week) фильтрует задачи по сроку выполнения; сегодняшний день и текущая неделя
+// определяются в часовом поясе пользователя (по умолчанию UTC),
+// параметр 'sort' (priority, position, due, created) сортирует задачи по приоритету, в порядке, заданном пользователем,
+// по сроку выполнения или по времени создания (сначала новые). По умолчанию задачи сортируются по приоритету и времени создания.
+// Выполненные задачи возвращаются только при 'include_completed=true', а 'completed=true' оставляет только выполненные задачи.
+// Параметры 'created_after', 'created_before', 'due_after' и 'due_before' (в формате RFC 3339) ограничивают время создания и срок выполнения.
+// Параметр 'list' оставляет только задачи из списка с указанным id, параметр 'tag' - только задачи с указанной меткой.
 func (c *TasksController) GetList(w http.ResponseWriter, r *http.Request) {
 	rawJwt := authorization.FromHeader(r.Header)
 	jwtClaims, err := authorization.GetClaims(rawJwt, jwtKey())
@@ -129,16 +424,90 @@ func (c *TasksController) GetList(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	list, err := c.tasksRepo.GetList(r.Context(), email)
+	opts, err := listOptionsFromQuery(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Сегодняшний день и текущая неделя определяются в часовом поясе пользователя
+	if opts.Due != models.DueAny {
+		user, err := c.usersRepo.GetByEmail(r.Context(), email)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		opts.Location, err = time.LoadLocation(user.TimeZone)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	// Запрашиваем на одну задачу больше, чтобы узнать, есть ли следующая страница
+	limit := opts.Limit
+	opts.Limit++
+
+	list, err := c.tasksRepo.GetList(r.Context(), email, opts)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	page := models.TaskPage{Tasks: list}
+	if len(list) > limit {
+		page.Tasks = list[:limit]
+		page.NextCursor = encodeCursor(models.CursorAt(list[limit-1], opts.Sort))
+	}
+
+	writeJson(w, page)
+}
+
+// Search ищет задачи пользователя по тексту и описанию на русском и английском языках.
+// Результаты упорядочены по релевантности и разбиты на страницы:
+//
+//	{"tasks": [...], "total": 42}
+//
+// Обрабатывает GET запросы по пути '/tasks/search'.
+// Обязательный параметр 'q' - строка поиска, параметры 'limit' (по умолчанию 20, не больше 100) и 'offset' задают страницу.
+// Выполненные задачи ищутся только при 'include_completed=true'.
+func (c *TasksController) Search(w http.ResponseWriter, r *http.Request) {
+	rawJwt := authorization.FromHeader(r.Header)
+	jwtClaims, err := authorization.GetClaims(rawJwt, jwtKey())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	email, err := jwtClaims.GetSubject()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	if !c.usersRepo.EmailExists(r.Context(), email) {
+		http.Error(w, errInvalidEmail.Error(), http.StatusForbidden)
+		return
+	}
+
+	q, err := searchQueryFromQuery(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	res, err := c.tasksRepo.Search(r.Context(), email, q)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	writeJson(w, &list)
+	writeJson(w, res)
 }
 
-// ClearList удаляет все задачи из списка пользователя.
+// ClearList перемещает все задачи из списка пользователя в корзину.
+// Очистку можно отменить запросом по пути '/tasks/undo'.
 //
 // Обрабатывает DELETE запросы по пути '/tasks/clear-list'.
 func (c *TasksController) ClearList(w http.ResponseWriter, r *http.Request) {
@@ -167,9 +536,9 @@ func (c *TasksController) ClearList(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-// DeleteTask удаляет задачу из списка пользователя.
+// DeleteTask перемещает задачу из списка пользователя в корзину.
 //
-// Обрабатывает DELETE запросы по пути '/tasks/del'.
+// Обрабатывает DELETE запросы по пути '/tasks/del/{id}'.
 func (c *TasksController) DeleteTask(w http.ResponseWriter, r *http.Request) {
 	rawJwt := authorization.FromHeader(r.Header)
 	jwtClaims, err := authorization.GetClaims(rawJwt, jwtKey())
@@ -195,9 +564,856 @@ func (c *TasksController) DeleteTask(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = c.tasksRepo.DeleteTask(r.Context(), taskId)
+	err = c.tasksRepo.DeleteTask(r.Context(), taskId, email)
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, errTaskNotFound.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// GetTrash возвращает задачи пользователя, находящиеся в корзине.
+// Задачи хранятся в корзине ограниченное время, после чего удаляются окончательно.
+//
+// Обрабатывает GET запросы по пути '/tasks/trash'.
+func (c *TasksController) GetTrash(w http.ResponseWriter, r *http.Request) {
+	rawJwt := authorization.FromHeader(r.Header)
+	jwtClaims, err := authorization.GetClaims(rawJwt, jwtKey())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	email, err := jwtClaims.GetSubject()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	if !c.usersRepo.EmailExists(r.Context(), email) {
+		http.Error(w, errInvalidEmail.Error(), http.StatusForbidden)
+		return
+	}
+
+	list, err := c.tasksRepo.GetTrash(r.Context(), email)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeJson(w, &list)
+}
+
+// GetHistory возвращает историю изменений задачи пользователя в хронологическом порядке:
+//
+//	[{"event_id": 1, "task_id": 5, "user_email": "...", "type": "created", "created_at": "..."},
+//	 {"event_id": 2, "task_id": 5, "user_email": "...", "type": "edited", "details": {"value": "Новый текст"}, "created_at": "..."}]
+//
+// История хранится, пока задача не удалена из корзины окончательно.
+//
+// Обрабатывает GET запросы по пути '/tasks/{id}/history'.
+func (c *TasksController) GetHistory(w http.ResponseWriter, r *http.Request) {
+	rawJwt := authorization.FromHeader(r.Header)
+	jwtClaims, err := authorization.GetClaims(rawJwt, jwtKey())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	email, err := jwtClaims.GetSubject()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	if !c.usersRepo.EmailExists(r.Context(), email) {
+		http.Error(w, errInvalidEmail.Error(), http.StatusForbidden)
+		return
+	}
+
+	taskId, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	events, err := c.tasksRepo.GetHistory(r.Context(), taskId, email)
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, errTaskNotFound.Error(), http.StatusNotFound)
+		return
+	}
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+
+	writeJson(w, &events)
+}
+
+// RestoreTask возвращает задачу пользователя из корзины в конец списка.
+//
+// Обрабатывает POST запросы по пути '/tasks/{id}/restore'.
+func (c *TasksController) RestoreTask(w http.ResponseWriter, r *http.Request) {
+	rawJwt := authorization.FromHeader(r.Header)
+	jwtClaims, err := authorization.GetClaims(rawJwt, jwtKey())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	email, err := jwtClaims.GetSubject()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	if !c.usersRepo.EmailExists(r.Context(), email) {
+		http.Error(w, errInvalidEmail.Error(), http.StatusForbidden)
+		return
+	}
+
+	taskId, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	err = c.tasksRepo.RestoreTask(r.Context(), taskId, email)
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, errTaskNotFound.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// UndoDelete отменяет последнее удаление: возвращает из корзины последнюю удалённую задачу
+// или весь список, если он был очищен. В ответе возвращается количество восстановленных задач:
+//
+//	{"restored": 3}
+//
+// Обрабатывает POST запросы по пути '/tasks/undo'.
+func (c *TasksController) UndoDelete(w http.ResponseWriter, r *http.Request) {
+	rawJwt := authorization.FromHeader(r.Header)
+	jwtClaims, err := authorization.GetClaims(rawJwt, jwtKey())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	email, err := jwtClaims.GetSubject()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	if !c.usersRepo.EmailExists(r.Context(), email) {
+		http.Error(w, errInvalidEmail.Error(), http.StatusForbidden)
+		return
+	}
+
+	n, err := c.tasksRepo.UndoDelete(r.Context(), email)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeJson(w, map[string]int64{"restored": n})
+}
+
+// CompleteTask отмечает задачу пользователя как выполненную.
+// Необязательный параметр 'done=false' снова делает задачу невыполненной.
+//
+// Если выполнена повторяющаяся задача, в ответе возвращается её следующее повторение.
+//
+// Обрабатывает PATCH запросы по пути '/tasks/complete/{id}'.
+func (c *TasksController) CompleteTask(w http.ResponseWriter, r *http.Request) {
+	rawJwt := authorization.FromHeader(r.Header)
+	jwtClaims, err := authorization.GetClaims(rawJwt, jwtKey())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	email, err := jwtClaims.GetSubject()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	if !c.usersRepo.EmailExists(r.Context(), email) {
+		http.Error(w, errInvalidEmail.Error(), http.StatusForbidden)
+		return
+	}
+
+	taskId, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	done := true
+	if v := r.URL.Query().Get("done"); v != "" {
+		done, err = strconv.ParseBool(v)
+		if err != nil {
+			http.Error(w, "invalid value for 'done' param", http.StatusBadRequest)
+			return
+		}
+	}
+
+	next, err := c.tasksRepo.SetCompleted(r.Context(), taskId, email, done)
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, errTaskNotFound.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if next != nil {
+		w.WriteHeader(http.StatusCreated)
+		writeJson(w, next)
+	}
+}
+
+// UpdateTask частично изменяет задачу пользователя. В теле запроса передаются только изменяемые поля,
+// null сбрасывает срок выполнения или время напоминания:
+//
+//	{"value": "Новый текст", "due_date": null}
+//
+// Обрабатывает PATCH запросы по пути '/tasks/{id}'.
+func (c *TasksController) UpdateTask(w http.ResponseWriter, r *http.Request) {
+	rawJwt := authorization.FromHeader(r.Header)
+	jwtClaims, err := authorization.GetClaims(rawJwt, jwtKey())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	email, err := jwtClaims.GetSubject()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	if !c.usersRepo.EmailExists(r.Context(), email) {
+		http.Error(w, errInvalidEmail.Error(), http.StatusForbidden)
+		return
+	}
+
+	taskId, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	patch, err := readBody[models.TaskPatch](r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if patch.Empty() {
+		http.Error(w, "nothing to update", http.StatusBadRequest)
+		return
+	}
+
+	if patch.Value != nil && *patch.Value == "" {
+		http.Error(w, "task value can't be empty", http.StatusBadRequest)
+		return
+	}
+
+	if patch.Priority != nil && !patch.Priority.Valid() {
+		http.Error(w, errInvalidPriority.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if patch.Notes != nil && utf8.RuneCountInString(*patch.Notes) > maxNotesLength {
+		http.Error(w, errNotesTooLong.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if patch.Recurrence != nil && *patch.Recurrence != "" {
+		if _, err = rrule.Parse(*patch.Recurrence); err != nil {
+			http.Error(w, fmt.Sprintf("invalid recurrence: %s", err), http.StatusBadRequest)
+			return
+		}
+	}
+
+	task, err := c.tasksRepo.UpdateTask(r.Context(), taskId, email, *patch)
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, errTaskNotFound.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeJson(w, task)
+}
+
+// ReorderTask перемещает задачу пользователя перед другой задачей того же списка или после неё.
+// В теле запроса указывается ровно одно из полей 'before' и 'after':
+//
+//	{"task_id": 5, "before": 7}
+//
+// Обрабатывает POST запросы по пути '/tasks/reorder'.
+func (c *TasksController) ReorderTask(w http.ResponseWriter, r *http.Request) {
+	rawJwt := authorization.FromHeader(r.Header)
+	jwtClaims, err := authorization.GetClaims(rawJwt, jwtKey())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	email, err := jwtClaims.GetSubject()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	if !c.usersRepo.EmailExists(r.Context(), email) {
+		http.Error(w, errInvalidEmail.Error(), http.StatusForbidden)
+		return
+	}
+
+	type reqBody struct {
+		TaskId int64  `json:"task_id"`
+		Before *int64 `json:"before"`
+		After  *int64 `json:"after"`
+	}
+
+	move, err := readBody[reqBody](r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if (move.Before == nil) == (move.After == nil) {
+		http.Error(w, "exactly one of 'before' and 'after' must be specified", http.StatusBadRequest)
+		return
+	}
+
+	anchorId, after := move.Before, false
+	if move.After != nil {
+		anchorId, after = move.After, true
+	}
+
+	err = c.tasksRepo.MoveTask(r.Context(), move.TaskId, email, *anchorId, after)
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, errTaskNotFound.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// AddTags добавляет метки задаче пользователя. Метки приводятся к нижнему регистру:
+//
+//	{"tags": ["work", "urgent"]}
+//
+// Обрабатывает POST запросы по пути '/tasks/{id}/tags'.
+func (c *TasksController) AddTags(w http.ResponseWriter, r *http.Request) {
+	rawJwt := authorization.FromHeader(r.Header)
+	jwtClaims, err := authorization.GetClaims(rawJwt, jwtKey())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	email, err := jwtClaims.GetSubject()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	if !c.usersRepo.EmailExists(r.Context(), email) {
+		http.Error(w, errInvalidEmail.Error(), http.StatusForbidden)
+		return
+	}
+
+	taskId, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	type reqBody struct {
+		Tags []string `json:"tags"`
+	}
+
+	body, err := readBody[reqBody](r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	tags := make([]string, 0, len(body.Tags))
+	for _, tag := range body.Tags {
+		tag = normalizeTag(tag)
+		if tag == "" {
+			http.Error(w, "tag can't be empty", http.StatusBadRequest)
+			return
+		}
+		tags = append(tags, tag)
+	}
+
+	if len(tags) == 0 {
+		http.Error(w, "no tags specified", http.StatusBadRequest)
+		return
+	}
+
+	err = c.tasksRepo.AddTags(r.Context(), taskId, email, tags)
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, errTaskNotFound.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// RemoveTag удаляет метку, указанную в пути запроса, у задачи пользователя.
+//
+// Обрабатывает DELETE запросы по пути '/tasks/{id}/tags/{tag}'.
+func (c *TasksController) RemoveTag(w http.ResponseWriter, r *http.Request) {
+	rawJwt := authorization.FromHeader(r.Header)
+	jwtClaims, err := authorization.GetClaims(rawJwt, jwtKey())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	email, err := jwtClaims.GetSubject()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	if !c.usersRepo.EmailExists(r.Context(), email) {
+		http.Error(w, errInvalidEmail.Error(), http.StatusForbidden)
+		return
+	}
+
+	taskId, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	tag := normalizeTag(r.PathValue("tag"))
+	if tag == "" {
+		http.Error(w, "invalid tag", http.StatusBadRequest)
+		return
+	}
+
+	err = c.tasksRepo.RemoveTag(r.Context(), taskId, email, tag)
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, errTaskNotFound.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// GetItems возвращает пункты чек-листа задачи пользователя.
+//
+// Обрабатывает GET запросы по пути '/tasks/{id}/items'.
+func (c *TasksController) GetItems(w http.ResponseWriter, r *http.Request) {
+	rawJwt := authorization.FromHeader(r.Header)
+	jwtClaims, err := authorization.GetClaims(rawJwt, jwtKey())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	email, err := jwtClaims.GetSubject()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	if !c.usersRepo.EmailExists(r.Context(), email) {
+		http.Error(w, errInvalidEmail.Error(), http.StatusForbidden)
+		return
+	}
+
+	taskId, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	items, err := c.tasksRepo.GetItems(r.Context(), taskId, email)
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, errTaskNotFound.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeJson(w, &items)
+}
+
+// AddItem добавляет пункт в конец чек-листа задачи пользователя:
+//
+//	{"value": "Купить молоко"}
+//
+// Обрабатывает POST запросы по пути '/tasks/{id}/items'.
+func (c *TasksController) AddItem(w http.ResponseWriter, r *http.Request) {
+	rawJwt := authorization.FromHeader(r.Header)
+	jwtClaims, err := authorization.GetClaims(rawJwt, jwtKey())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	email, err := jwtClaims.GetSubject()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	if !c.usersRepo.EmailExists(r.Context(), email) {
+		http.Error(w, errInvalidEmail.Error(), http.StatusForbidden)
+		return
+	}
+
+	taskId, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	type reqBody struct {
+		Value string `json:"value"`
+	}
+
+	body, err := readBody[reqBody](r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if body.Value == "" {
+		http.Error(w, "item value can't be empty", http.StatusBadRequest)
+		return
+	}
+
+	item, err := c.tasksRepo.AddItem(r.Context(), taskId, email, body.Value)
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, errTaskNotFound.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	writeJson(w, item)
+}
+
+// UpdateItem частично изменяет пункт чек-листа задачи пользователя, например отмечает его выполненным:
+//
+//	{"done": true}
+//
+// Обрабатывает PATCH запросы по пути '/tasks/{id}/items/{item}'.
+func (c *TasksController) UpdateItem(w http.ResponseWriter, r *http.Request) {
+	rawJwt := authorization.FromHeader(r.Header)
+	jwtClaims, err := authorization.GetClaims(rawJwt, jwtKey())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	email, err := jwtClaims.GetSubject()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	if !c.usersRepo.EmailExists(r.Context(), email) {
+		http.Error(w, errInvalidEmail.Error(), http.StatusForbidden)
+		return
+	}
+
+	taskId, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	itemId, err := strconv.ParseInt(r.PathValue("item"), 10, 64)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	patch, err := readBody[models.TaskItemPatch](r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if patch.Empty() {
+		http.Error(w, "nothing to update", http.StatusBadRequest)
+		return
+	}
+
+	if patch.Value != nil && *patch.Value == "" {
+		http.Error(w, "item value can't be empty", http.StatusBadRequest)
+		return
+	}
+
+	item, err := c.tasksRepo.UpdateItem(r.Context(), taskId, itemId, email, *patch)
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, errItemNotFound.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeJson(w, item)
+}
+
+// DeleteItem удаляет пункт из чек-листа задачи пользователя.
+//
+// Обрабатывает DELETE запросы по пути '/tasks/{id}/items/{item}'.
+func (c *TasksController) DeleteItem(w http.ResponseWriter, r *http.Request) {
+	rawJwt := authorization.FromHeader(r.Header)
+	jwtClaims, err := authorization.GetClaims(rawJwt, jwtKey())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	email, err := jwtClaims.GetSubject()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	if !c.usersRepo.EmailExists(r.Context(), email) {
+		http.Error(w, errInvalidEmail.Error(), http.StatusForbidden)
+		return
+	}
+
+	taskId, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	itemId, err := strconv.ParseInt(r.PathValue("item"), 10, 64)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	err = c.tasksRepo.DeleteItem(r.Context(), taskId, itemId, email)
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, errItemNotFound.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// validateTask проверяет поля новой или изменённой задачи.
+func validateTask(priority models.Priority, notes, recurrence string) error {
+	if !priority.Valid() {
+		return errInvalidPriority
+	}
+
+	if utf8.RuneCountInString(notes) > maxNotesLength {
+		return errNotesTooLong
+	}
+
+	if recurrence != "" {
+		if _, err := rrule.Parse(recurrence); err != nil {
+			return fmt.Errorf("invalid recurrence: %s", err)
+		}
+	}
+
+	return nil
+}
+
+// validateBatchOperation проверяет, что у операции пакетного запроса указаны все нужные ей поля.
+func validateBatchOperation(op models.BatchOperation) error {
+	switch op.Op {
+	case models.BatchCreate:
+		if op.Task == nil {
+			return errors.New("'task' is required for 'create'")
+		}
+		return validateTask(op.Task.Priority, op.Task.Notes, op.Task.Recurrence)
+	case models.BatchComplete, models.BatchDelete:
+		if op.TaskId == 0 {
+			return fmt.Errorf("'task_id' is required for '%s'", op.Op)
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown operation %q", op.Op)
+}
+
+// normalizeTag убирает пробелы по краям метки и приводит её к нижнему регистру.
+func normalizeTag(tag string) string {
+	return strings.ToLower(strings.TrimSpace(tag))
+}
+
+// listOptionsFromQuery получает параметры выборки списка задач из query параметров запроса.
+func listOptionsFromQuery(q url.Values) (models.ListOptions, error) {
+	opts := models.ListOptions{
+		Due: models.DueFilter(q.Get("due")),
+	}
+
+	if !opts.Due.Valid() {
+		return models.ListOptions{}, errors.New("invalid value for 'due' param")
+	}
+
+	if v := q.Get("list"); v != "" {
+		listId, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return models.ListOptions{}, errors.New("invalid value for 'list' param")
+		}
+		opts.ListId = &listId
+	}
+
+	if v := q.Get("tag"); v != "" {
+		opts.Tag = normalizeTag(v)
+	}
+
+	if v := q.Get("include_completed"); v != "" {
+		include, err := strconv.ParseBool(v)
+		if err != nil {
+			return models.ListOptions{}, errors.New("invalid value for 'include_completed' param")
+		}
+		opts.IncludeCompleted = include
+	}
+
+	if v := q.Get("completed"); v != "" {
+		completed, err := strconv.ParseBool(v)
+		if err != nil {
+			return models.ListOptions{}, errors.New("invalid value for 'completed' param")
+		}
+		opts.Completed = &completed
+	}
+
+	opts.Sort = models.TaskSort(q.Get("sort"))
+	if !opts.Sort.Valid() {
+		return models.ListOptions{}, errors.New("invalid value for 'sort' param")
+	}
+
+	timeParams := []struct {
+		name string
+		dst  **time.Time
+	}{
+		{"created_after", &opts.CreatedFrom},
+		{"created_before", &opts.CreatedTo},
+		{"due_after", &opts.DueFrom},
+		{"due_before", &opts.DueTo},
+	}
+	for _, p := range timeParams {
+		if v := q.Get(p.name); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				return models.ListOptions{}, fmt.Errorf("invalid value for '%s' param: expected RFC 3339 time", p.name)
+			}
+			*p.dst = &t
+		}
+	}
+
+	opts.Limit = defaultListLimit
+	if v := q.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit <= 0 || limit > maxListLimit {
+			return models.ListOptions{}, fmt.Errorf("invalid value for 'limit' param: expected 1-%d", maxListLimit)
+		}
+		opts.Limit = limit
+	}
+
+	if v := q.Get("cursor"); v != "" {
+		cursor, err := decodeCursor(v)
+		if err != nil || cursor.Sort != opts.Sort {
+			return models.ListOptions{}, errors.New("invalid value for 'cursor' param")
+		}
+		opts.After = &cursor
+	}
+
+	return opts, nil
+}
+
+// encodeCursor преобразует курсор в непрозрачную строку, которую клиент передаёт в параметре 'cursor'.
+func encodeCursor(c models.ListCursor) string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// decodeCursor восстанавливает курсор из строки, полученной от encodeCursor.
+func decodeCursor(s string) (models.ListCursor, error) {
+	var c models.ListCursor
+
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return c, err
+	}
+
+	err = json.Unmarshal(b, &c)
+	return c, err
+}
+
+// searchQueryFromQuery получает параметры поиска задач из query параметров запроса.
+func searchQueryFromQuery(q url.Values) (models.SearchQuery, error) {
+	sq := models.SearchQuery{
+		Text:  strings.TrimSpace(q.Get("q")),
+		Limit: defaultSearchLimit,
+	}
+
+	if sq.Text == "" {
+		return models.SearchQuery{}, errors.New("'q' param is required")
+	}
+
+	if v := q.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit <= 0 || limit > maxSearchLimit {
+			return models.SearchQuery{}, fmt.Errorf("invalid value for 'limit' param: expected 1-%d", maxSearchLimit)
+		}
+		sq.Limit = limit
+	}
+
+	if v := q.Get("offset"); v != "" {
+		offset, err := strconv.Atoi(v)
+		if err != nil || offset < 0 {
+			return models.SearchQuery{}, errors.New("invalid value for 'offset' param")
+		}
+		sq.Offset = offset
+	}
+
+	if v := q.Get("include_completed"); v != "" {
+		include, err := strconv.ParseBool(v)
+		if err != nil {
+			return models.SearchQuery{}, errors.New("invalid value for 'include_completed' param")
+		}
+		sq.IncludeCompleted = include
+	}
+
+	return sq, nil
 }
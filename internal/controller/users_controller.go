@@ -2,40 +2,58 @@ package controller
 
 import (
 	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/artemwebber1/friendly_reminder/internal/config"
 	"github.com/artemwebber1/friendly_reminder/internal/hasher"
 	"github.com/artemwebber1/friendly_reminder/internal/models"
 	"github.com/artemwebber1/friendly_reminder/pkg/authorization"
 	"github.com/artemwebber1/friendly_reminder/pkg/cors"
+	"github.com/artemwebber1/friendly_reminder/pkg/cron"
 	"github.com/artemwebber1/friendly_reminder/pkg/email"
 	"github.com/artemwebber1/friendly_reminder/pkg/logging"
-	"github.com/golang-jwt/jwt/v5"
 )
 
 type usersRepository interface {
 	// AddUser добавляет нового пользователя.
 	AddUser(ctx context.Context, email, password string) error
 
-	// DeleteUser удаляет пользователя из базы данных.
+	// DeleteUser удаляет пользователя из базы данных вместе со всеми его задачами в одной транзакции.
 	DeleteUser(ctx context.Context, email string) error
 
+	// ExportData возвращает все списки и задачи пользователя с указанной почтой.
+	ExportData(ctx context.Context, email string) (models.UserExport, error)
+
 	// Subscribe подписывает пользователя на рассылку электронных писем.
 	// Если параметр subscribe = true, пользователь будет подписан на рассылку, иначе будет отписан.
 	Subscribe(ctx context.Context, email string, subscr bool) error
 
+	// SetDigestSchedule устанавливает пользователю расписание рассылки списка дел в виде cron выражения и часовой пояс,
+	// в котором это расписание вычисляется. Пустое расписание означает рассылку с интервалом по умолчанию.
+	SetDigestSchedule(ctx context.Context, email, schedule, timeZone string) error
+
+	// SetDigestGroup устанавливает способ группировки задач в письме со списком дел пользователя.
+	SetDigestGroup(ctx context.Context, email string, group models.DigestGroup) error
+
 	// GetByEmail возвращает пользователя с указанным email.
 	GetByEmail(ctx context.Context, email string) (*models.User, error)
 
 	// EmailExists возвращает true если пользователь с данной электронной почтой уже существует.
 	EmailExists(ctx context.Context, email string) bool
 
-	// UserExists возвращает true, если существует пользователь с указанной почтой и паролем.
-	UserExists(ctx context.Context, email, password string) bool
+	// GetPasswordHash возвращает хэш пароля пользователя с указанной почтой.
+	// Если такого пользователя нет, возвращает sql.ErrNoRows.
+	GetPasswordHash(ctx context.Context, email string) (string, error)
+
+	// SetPasswordHash заменяет хэш пароля пользователя с указанной почтой.
+	SetPasswordHash(ctx context.Context, email, hash string) error
 }
 
 // unverifiedUsersRepository является репозиторием неверифицированных пользователей.
@@ -43,24 +61,67 @@ type usersRepository interface {
 // Неверифицированный пользователь - это пользователь, который
 // регистрировался в системе, но не подтвердил электронную почту.
 type unverifiedUsersRepository interface {
-	// TokenExists возвращает true если существует указанный токен для подтверждения электронной почты.
-	TokenExists(t string) bool
-
 	// CreateToken добавляет пользователя в базу данных, как не подтвердившего электронную почту, и создаёт токен для подтверждения.
 	// Возвращает сам токен и ошибку.
 	CreateToken(email, pwd string) (string, error)
 
-	// DeleteToken удаляет токен из базы данных.
-	DeleteToken(token string) error
-
 	// UpdateToken создаёт новый токен для пользователя с указанным email.
 	UpdateToken(email string) (string, error)
 
 	// HasToken возвращает true, если для указанной электронной почты уже сгенерирован токен.
 	HasToken(email string) bool
 
-	// GetUserByToken получает пользователя по токену.
-	GetUserByToken(token string) (models.User, error)
+	// ConfirmUser подтверждает почту пользователя по токену: добавляет его в таблицу users и удаляет токен.
+	// Возвращает почту пользователя. Если токен не существует, возвращает sql.ErrNoRows, а если почта уже занята - models.ErrEmailTaken.
+	ConfirmUser(ctx context.Context, token string) (string, error)
+}
+
+// sessionsRepository является репозиторием сессий пользователей.
+//
+// Сессия создаётся при входе пользователя и хранит хэш его refresh токена.
+type sessionsRepository interface {
+	// CreateSession создаёт сессию пользователя email с refresh токеном, хэш которого равен refreshHash,
+	// действующим до момента expiresAt. Возвращает id сессии.
+	CreateSession(ctx context.Context, email, refreshHash string, expiresAt time.Time) (int64, error)
+
+	// RotateRefreshToken заменяет действующий refresh токен сессии с хэшем oldHash на токен с хэшем newHash,
+	// действующий до момента expiresAt. Возвращает id сессии и почту её пользователя.
+	// Если действующего токена с хэшем oldHash нет, возвращает sql.ErrNoRows.
+	RotateRefreshToken(ctx context.Context, oldHash, newHash string, expiresAt time.Time) (int64, string, error)
+
+	// DeleteSession завершает сессию пользователя email с указанным id.
+	// Если такой сессии у пользователя нет, возвращает sql.ErrNoRows.
+	DeleteSession(ctx context.Context, id int64, email string) error
+
+	// DeleteOtherSessions завершает все сессии пользователя email, кроме сессии с id keep.
+	DeleteOtherSessions(ctx context.Context, email string, keep int64) error
+}
+
+// passwordResetsRepository является репозиторием токенов для сброса пароля.
+type passwordResetsRepository interface {
+	// CreateResetToken создаёт токен для сброса пароля пользователя email, действующий до момента expiresAt, и возвращает его.
+	// Ранее выданные пользователю токены при этом перестают действовать.
+	CreateResetToken(ctx context.Context, email string, expiresAt time.Time) (string, error)
+
+	// ResetPassword заменяет хэш пароля пользователя, которому выдан токен token, на passwordHash
+	// и завершает все сессии пользователя. Токен после этого перестаёт действовать.
+	// Возвращает почту пользователя. Если токен не существует или истёк, возвращает sql.ErrNoRows.
+	ResetPassword(ctx context.Context, token, passwordHash string) (string, error)
+}
+
+// emailChangesRepository является репозиторием запросов на смену почты.
+type emailChangesRepository interface {
+	// CreateEmailChange создаёт запрос на смену почты пользователя email на newEmail, действующий до момента expiresAt,
+	// и возвращает токен для его подтверждения. Предыдущие запросы пользователя при этом перестают действовать.
+	CreateEmailChange(ctx context.Context, email, newEmail string, expiresAt time.Time) (string, error)
+
+	// HasPendingChange возвращает true, если есть действующий запрос на смену почты какого-либо пользователя на email.
+	HasPendingChange(ctx context.Context, email string) bool
+
+	// ChangeEmail меняет почту пользователя по токену подтверждения, вместе с почтой владельца его задач,
+	// и возвращает старую и новую почту. Все сессии пользователя при этом завершаются.
+	// Если токен не существует или истёк, возвращает sql.ErrNoRows, а если новая почта уже занята - models.ErrEmailTaken.
+	ChangeEmail(ctx context.Context, token string) (string, string, error)
 }
 
 type UsersController struct {
@@ -69,16 +130,25 @@ type UsersController struct {
 
 	usersRepo           usersRepository
 	unverifiedUsersRepo unverifiedUsersRepository
+	sessionsRepo        sessionsRepository
+	passwordResetsRepo  passwordResetsRepository
+	emailChangesRepo    emailChangesRepository
 }
 
 func NewUsersController(
 	ur usersRepository,
 	uur unverifiedUsersRepository,
+	sr sessionsRepository,
+	prr passwordResetsRepository,
+	ecr emailChangesRepository,
 	emailSender email.Sender,
 	cfg *config.Config) *UsersController {
 	return &UsersController{
 		usersRepo:           ur,
 		unverifiedUsersRepo: uur,
+		sessionsRepo:        sr,
+		passwordResetsRepo:  prr,
+		emailChangesRepo:    ecr,
 		emailSender:         emailSender,
 		cfg:                 cfg,
 	}
@@ -95,6 +165,52 @@ func (c *UsersController) AddEndpoints(mux *http.ServeMux) {
 		logging.Middleware(cors.Middleware(c.Login)),
 	)
 
+	mux.HandleFunc(
+		c.cfg.Prefix+"/users/refresh",
+		logging.Middleware(cors.Middleware(c.Refresh)),
+	)
+
+	mux.HandleFunc(
+		c.cfg.Prefix+"/users/logout",
+		logging.Middleware(cors.Middleware(authorization.Middleware(c.Logout))),
+	)
+
+	mux.HandleFunc(
+		c.cfg.Prefix+"/users/forgot-password",
+		logging.Middleware(cors.Middleware(c.ForgotPassword)),
+	)
+
+	mux.HandleFunc(
+		c.cfg.Prefix+"/users/reset-password",
+		logging.Middleware(cors.Middleware(c.ResetPassword)),
+	)
+
+	mux.HandleFunc(
+		c.cfg.Prefix+"/users/me",
+		logging.Middleware(cors.Middleware(authorization.Middleware(byMethod(map[string]http.HandlerFunc{
+			http.MethodDelete: c.DeleteMe,
+		})))),
+	)
+
+	mux.HandleFunc(
+		c.cfg.Prefix+"/users/me/password",
+		logging.Middleware(cors.Middleware(authorization.Middleware(byMethod(map[string]http.HandlerFunc{
+			http.MethodPatch: c.ChangePassword,
+		})))),
+	)
+
+	mux.HandleFunc(
+		c.cfg.Prefix+"/users/me/email",
+		logging.Middleware(cors.Middleware(authorization.Middleware(byMethod(map[string]http.HandlerFunc{
+			http.MethodPost: c.RequestEmailChange,
+		})))),
+	)
+
+	mux.HandleFunc(
+		c.cfg.Prefix+"/users/me/email/confirm",
+		logging.Middleware(cors.Middleware(c.ConfirmEmailChange)),
+	)
+
 	mux.HandleFunc(
 		c.cfg.Prefix+"/users/confirm-email",
 		logging.Middleware(cors.Middleware(c.ConfirmEmail)),
@@ -105,6 +221,16 @@ func (c *UsersController) AddEndpoints(mux *http.ServeMux) {
 		logging.Middleware(cors.Middleware(authorization.Middleware(c.SubscribeUser))),
 	)
 
+	mux.HandleFunc(
+		c.cfg.Prefix+"/users/schedule",
+		logging.Middleware(cors.Middleware(authorization.Middleware(c.SetDigestSchedule))),
+	)
+
+	mux.HandleFunc(
+		c.cfg.Prefix+"/users/digest-group",
+		logging.Middleware(cors.Middleware(authorization.Middleware(c.SetDigestGroup))),
+	)
+
 	mux.HandleFunc(
 		c.cfg.Prefix+"/users/{email}",
 		logging.Middleware(cors.Middleware(c.GetByEmail)),
@@ -130,7 +256,8 @@ func (c *UsersController) SendConfirmEmailLink(w http.ResponseWriter, r *http.Re
 		return
 	}
 
-	if c.usersRepo.EmailExists(r.Context(), user.Email) {
+	// Адрес, на который другой пользователь меняет почту, тоже считается занятым
+	if c.usersRepo.EmailExists(r.Context(), user.Email) || c.emailChangesRepo.HasPendingChange(r.Context(), user.Email) {
 		http.Error(w, "user with this email already exists", http.StatusForbidden)
 		return
 	}
@@ -169,28 +296,24 @@ func (c *UsersController) SendConfirmEmailLink(w http.ResponseWriter, r *http.Re
 // Обрабатывает GET запросы по пути '/users/confirm-email'.
 func (c *UsersController) ConfirmEmail(w http.ResponseWriter, r *http.Request) {
 	token := r.URL.Query().Get("t")
-	if !c.unverifiedUsersRepo.TokenExists(token) {
+
+	// Пользователь добавляется в базу данных в той же транзакции, в которой удаляется токен
+	_, err := c.unverifiedUsersRepo.ConfirmUser(r.Context(), token)
+	if errors.Is(err, sql.ErrNoRows) {
 		http.Error(w, "invalid confirm token", http.StatusForbidden)
 		return
 	}
-
-	user, err := c.unverifiedUsersRepo.GetUserByToken(token)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	if errors.Is(err, models.ErrEmailTaken) {
+		http.Error(w, err.Error(), http.StatusConflict)
 		return
 	}
-
-	err = c.unverifiedUsersRepo.DeleteToken(token)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("failed to delete confirm token: %s", err), http.StatusForbidden)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	w.WriteHeader(http.StatusCreated)
 	w.Write([]byte("Почта подтверждена"))
-
-	// Пользователь успешно подтвердил электронную почту, добавляем его в базу данных
-	c.usersRepo.AddUser(r.Context(), user.Email, user.Password)
 }
 
 // SubscribeUser подписывает пользователя с указанным email на рассылку писем.
@@ -222,7 +345,13 @@ func (c *UsersController) SubscribeUser(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	body := "Вы подписались на рассылку. Теперь ваш список дел будет приходить к вам на почту каждые 6 часов"
+	u, err := c.usersRepo.GetByEmail(r.Context(), email)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	body := "Вы подписались на рассылку. Теперь ваш список дел будет приходить к вам на почту " + c.digestPeriodText(u)
 	if !subscribe {
 		body = "Вы отписались от рассылки"
 	}
@@ -230,6 +359,154 @@ func (c *UsersController) SubscribeUser(w http.ResponseWriter, r *http.Request)
 	c.usersRepo.Subscribe(r.Context(), email, subscribe)
 }
 
+// SetDigestSchedule устанавливает расписание, по которому пользователю будет приходить список дел.
+// Тело запроса содержит cron выражение из пяти полей и часовой пояс IANA, например:
+//
+//	{"digest_schedule": "30 8 * * 1-5", "time_zone": "Europe/Moscow"}
+//
+// Пустое выражение возвращает рассылку с интервалом по умолчанию.
+//
+// Обрабатывает PATCH запросы по пути '/users/schedule'.
+func (c *UsersController) SetDigestSchedule(w http.ResponseWriter, r *http.Request) {
+	rawJwt := authorization.FromHeader(r.Header)
+
+	jwtClaims, err := authorization.GetClaims(rawJwt, jwtKey())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	email, err := jwtClaims.GetSubject()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	if !c.usersRepo.EmailExists(r.Context(), email) {
+		http.Error(w, errInvalidEmail.Error(), http.StatusForbidden)
+		return
+	}
+
+	type reqBody struct {
+		DigestSchedule string `json:"digest_schedule"`
+		TimeZone       string `json:"time_zone"`
+	}
+
+	sched, err := readBody[reqBody](r.Body)
+	if err != nil {
+		http.Error(w, errReadingBody.Error(), http.StatusBadRequest)
+		return
+	}
+
+	u := models.User{
+		Email:          email,
+		DigestSchedule: sched.DigestSchedule,
+		TimeZone:       sched.TimeZone,
+	}
+
+	loc, err := time.LoadLocation(u.TimeZone)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid time zone: %s", err), http.StatusBadRequest)
+		return
+	}
+
+	// Проверяем, что расписание корректно и когда-нибудь сработает
+	next := time.Now().Add(c.digestDelay())
+	if u.DigestSchedule != "" {
+		sched, err := cron.Parse(u.DigestSchedule)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("invalid schedule: %s", err), http.StatusBadRequest)
+			return
+		}
+
+		next = sched.Next(time.Now().In(loc))
+		if next.IsZero() {
+			http.Error(w, "invalid schedule: schedule never fires", http.StatusBadRequest)
+			return
+		}
+	}
+
+	err = c.usersRepo.SetDigestSchedule(r.Context(), email, u.DigestSchedule, u.TimeZone)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	res := struct {
+		DigestSchedule string    `json:"digest_schedule"`
+		TimeZone       string    `json:"time_zone"`
+		NextDigestAt   time.Time `json:"next_digest_at"`
+	}{
+		DigestSchedule: u.DigestSchedule,
+		TimeZone:       u.TimeZone,
+		NextDigestAt:   next,
+	}
+
+	writeJson(w, res)
+}
+
+// SetDigestGroup устанавливает способ группировки задач в письме со списком дел.
+// Параметр 'by=tag' группирует задачи по меткам, пустой параметр возвращает список без группировки.
+//
+// Обрабатывает PATCH запросы по пути '/users/digest-group'.
+func (c *UsersController) SetDigestGroup(w http.ResponseWriter, r *http.Request) {
+	rawJwt := authorization.FromHeader(r.Header)
+
+	jwtClaims, err := authorization.GetClaims(rawJwt, jwtKey())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	email, err := jwtClaims.GetSubject()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	if !c.usersRepo.EmailExists(r.Context(), email) {
+		http.Error(w, errInvalidEmail.Error(), http.StatusForbidden)
+		return
+	}
+
+	group := models.DigestGroup(r.URL.Query().Get("by"))
+	if !group.Valid() {
+		http.Error(w, "invalid value for 'by' param", http.StatusBadRequest)
+		return
+	}
+
+	err = c.usersRepo.SetDigestGroup(r.Context(), email, group)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// digestDelay возвращает интервал рассылки списка дел для пользователей без собственного расписания.
+func (c *UsersController) digestDelay() time.Duration {
+	return c.cfg.ListSenderOptions.Delay * time.Second
+}
+
+// digestPeriodText возвращает описание того, как часто пользователю приходит список дел.
+func (c *UsersController) digestPeriodText(u *models.User) string {
+	if u.DigestSchedule != "" {
+		tz := u.TimeZone
+		if tz == "" {
+			tz = "UTC"
+		}
+		return fmt.Sprintf("по расписанию '%s' (часовой пояс %s)", u.DigestSchedule, tz)
+	}
+
+	d := c.digestDelay()
+	switch {
+	case d%time.Hour == 0:
+		return fmt.Sprintf("каждые %d ч.", d/time.Hour)
+	case d%time.Minute == 0:
+		return fmt.Sprintf("каждые %d мин.", d/time.Minute)
+	}
+	return fmt.Sprintf("каждые %s", d)
+}
+
 func (c *UsersController) GetByEmail(w http.ResponseWriter, r *http.Request) {
 	email := r.PathValue("email")
 	u, err := c.usersRepo.GetByEmail(r.Context(), email)
@@ -248,7 +525,10 @@ func (c *UsersController) GetByEmail(w http.ResponseWriter, r *http.Request) {
 	writeJson(w, res)
 }
 
-// Login осуществляет вход уже существующего пользователя в систему.
+// Login осуществляет вход уже существующего пользователя в систему и начинает новую сессию.
+// Возвращает короткоживущий access токен и refresh токен, по которому можно получить новую пару токенов:
+//
+//	{"access_token": "...", "refresh_token": "...", "expires_in": 900}
 //
 // Обрабатывает POST запросы по пути '/users/login'.
 func (c *UsersController) Login(w http.ResponseWriter, r *http.Request) {
@@ -258,23 +538,501 @@ func (c *UsersController) Login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if !c.usersRepo.UserExists(r.Context(), user.Email, hasher.Hash(user.Password)) {
+	hash, err := c.usersRepo.GetPasswordHash(r.Context(), user.Email)
+	if err != nil {
+		// Пароль всё равно проверяется, чтобы по времени ответа нельзя было узнать, зарегистрирована ли почта
+		hasher.Verify(user.Password, dummyHash)
 		http.Error(w, "invalid email or password", http.StatusForbidden)
 		return
 	}
 
-	// Создание jwt
-	claims := jwt.MapClaims{
-		"sub": user.Email,
-		//"exp": time.Now().Add(time.Hour).Unix(),
+	if !hasher.Verify(user.Password, hash) {
+		http.Error(w, "invalid email or password", http.StatusForbidden)
+		return
+	}
+
+	// Пароли, захэшированные старым способом, хэшируются заново, пока известен сам пароль
+	if hasher.NeedsRehash(hash) {
+		err = c.usersRepo.SetPasswordHash(r.Context(), user.Email, hasher.Hash(user.Password))
+		if err != nil {
+			log.Printf("Failed to rehash password of '%s': %s", user.Email, err)
+		}
 	}
 
-	tok := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokStr, err := tok.SignedString(jwtKey())
+	refreshToken, refreshHash := authorization.NewRefreshToken()
+	sessionId, err := c.sessionsRepo.CreateSession(r.Context(), user.Email, refreshHash, time.Now().Add(c.refreshTTL()))
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	w.Write([]byte(tokStr))
+	c.writeTokens(w, user.Email, sessionId, refreshToken)
+}
+
+// Refresh выдаёт новую пару токенов по refresh токену:
+//
+//	{"refresh_token": "..."}
+//
+// Refresh токен одноразовый: после обновления старый токен перестаёт действовать.
+// Если уже заменённый токен предъявлен повторно, сессия завершается, и новый токен тоже перестаёт действовать.
+//
+// Обрабатывает POST запросы по пути '/users/refresh'.
+func (c *UsersController) Refresh(w http.ResponseWriter, r *http.Request) {
+	type reqBody struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+
+	body, err := readBody[reqBody](r.Body)
+	if err != nil {
+		http.Error(w, errReadingBody.Error(), http.StatusBadRequest)
+		return
+	}
+
+	refreshToken, refreshHash := authorization.NewRefreshToken()
+	sessionId, email, err := c.sessionsRepo.RotateRefreshToken(
+		r.Context(),
+		authorization.HashToken(body.RefreshToken),
+		refreshHash,
+		time.Now().Add(c.refreshTTL()))
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, errInvalidRefreshToken.Error(), http.StatusForbidden)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	c.writeTokens(w, email, sessionId, refreshToken)
+}
+
+// Logout завершает сессию, в рамках которой выдан токен пользователя.
+// После этого ни access, ни refresh токен этой сессии не действуют.
+//
+// Обрабатывает POST запросы по пути '/users/logout'.
+func (c *UsersController) Logout(w http.ResponseWriter, r *http.Request) {
+	rawJwt := authorization.FromHeader(r.Header)
+	jwtClaims, err := authorization.GetClaims(rawJwt, jwtKey())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	email, err := jwtClaims.GetSubject()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	sessionId, err := authorization.GetSessionId(jwtClaims)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	err = c.sessionsRepo.DeleteSession(r.Context(), sessionId, email)
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "session not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// writeTokens создаёт access токен сессии sessionId и отправляет его пользователю вместе с refresh токеном.
+func (c *UsersController) writeTokens(w http.ResponseWriter, email string, sessionId int64, refreshToken string) {
+	ttl := c.accessTTL()
+	accessToken, err := authorization.NewAccessToken(email, sessionId, ttl, jwtKey())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	res := struct {
+		AccessToken  string `json:"access_token"`
+		RefreshToken string `json:"refresh_token"`
+		ExpiresIn    int64  `json:"expires_in"` // Через сколько секунд истекает access токен
+	}{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int64(ttl / time.Second),
+	}
+
+	writeJson(w, res)
+}
+
+// ForgotPassword отправляет пользователю на почту одноразовую ссылку для сброса пароля:
+//
+//	{"email": "user@mail.com"}
+//
+// Ответ не зависит от того, существует ли пользователь, чтобы по нему нельзя было узнать зарегистрированные адреса.
+//
+// Обрабатывает POST запросы по пути '/users/forgot-password'.
+func (c *UsersController) ForgotPassword(w http.ResponseWriter, r *http.Request) {
+	type reqBody struct {
+		Email string `json:"email"`
+	}
+
+	body, err := readBody[reqBody](r.Body)
+	if err != nil {
+		http.Error(w, errReadingBody.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Поиск пользователя, создание токена и отправка письма выполняются после ответа,
+	// чтобы время ответа тоже не зависело от того, существует ли пользователь
+	go c.sendResetLink(context.WithoutCancel(r.Context()), body.Email)
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// sendResetLink отправляет на почту email ссылку для сброса пароля, если пользователь с такой почтой существует.
+func (c *UsersController) sendResetLink(ctx context.Context, email string) {
+	if !c.usersRepo.EmailExists(ctx, email) {
+		return
+	}
+
+	resetToken, err := c.passwordResetsRepo.CreateResetToken(ctx, email, time.Now().Add(c.resetTTL()))
+	if err != nil {
+		log.Printf("Failed to create password reset token for '%s': %s", email, err)
+		return
+	}
+
+	// Ссылка для сброса пароля
+	resetLink := c.cfg.Host + ":" + c.cfg.Port + c.cfg.Prefix + "/users/reset-password?t=" + resetToken
+
+	log.Printf("Sending a password reset link to '%s'...\n", email)
+
+	const subject = "Friendly reminder: сброс пароля"
+	text := fmt.Sprintf(
+		"Чтобы задать новый пароль, отправьте POST запрос с токеном и новым паролем по ссылке:\n%s\n\nСсылка действует %d мин.\nЕсли вы не запрашивали сброс пароля, проигнорируйте это письмо.",
+		resetLink, int(c.resetTTL()/time.Minute))
+
+	if err = c.emailSender.Send(subject, text, email); err != nil {
+		log.Println(err)
+	}
+}
+
+// ResetPassword задаёт пользователю новый пароль по токену из письма и завершает все его сессии.
+// Токен передаётся в параметре 't' или в теле запроса:
+//
+//	{"token": "...", "password": "новый пароль"}
+//
+// Обрабатывает POST запросы по пути '/users/reset-password'.
+func (c *UsersController) ResetPassword(w http.ResponseWriter, r *http.Request) {
+	type reqBody struct {
+		Token    string `json:"token"`
+		Password string `json:"password"`
+	}
+
+	body, err := readBody[reqBody](r.Body)
+	if err != nil {
+		http.Error(w, errReadingBody.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if body.Token == "" {
+		body.Token = r.URL.Query().Get("t")
+	}
+
+	if body.Password == "" {
+		http.Error(w, "password can't be empty", http.StatusBadRequest)
+		return
+	}
+
+	_, err = c.passwordResetsRepo.ResetPassword(r.Context(), body.Token, hasher.Hash(body.Password))
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "invalid or expired reset token", http.StatusForbidden)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Write([]byte("Пароль изменён"))
+}
+
+// ChangePassword меняет пароль пользователя. Для смены пароля нужно указать текущий пароль:
+//
+//	{"old_password": "текущий пароль", "new_password": "новый пароль"}
+//
+// Все сессии пользователя, кроме текущей, завершаются.
+//
+// Обрабатывает PATCH запросы по пути '/users/me/password'.
+func (c *UsersController) ChangePassword(w http.ResponseWriter, r *http.Request) {
+	rawJwt := authorization.FromHeader(r.Header)
+	jwtClaims, err := authorization.GetClaims(rawJwt, jwtKey())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	email, err := jwtClaims.GetSubject()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	if !c.usersRepo.EmailExists(r.Context(), email) {
+		http.Error(w, errInvalidEmail.Error(), http.StatusForbidden)
+		return
+	}
+
+	type reqBody struct {
+		OldPassword string `json:"old_password"`
+		NewPassword string `json:"new_password"`
+	}
+
+	body, err := readBody[reqBody](r.Body)
+	if err != nil {
+		http.Error(w, errReadingBody.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if body.NewPassword == "" {
+		http.Error(w, "password can't be empty", http.StatusBadRequest)
+		return
+	}
+
+	if !c.checkPassword(r.Context(), email, body.OldPassword) {
+		http.Error(w, errWrongPassword.Error(), http.StatusForbidden)
+		return
+	}
+
+	err = c.usersRepo.SetPasswordHash(r.Context(), email, hasher.Hash(body.NewPassword))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	sessionId, err := authorization.GetSessionId(jwtClaims)
+	if err == nil {
+		err = c.sessionsRepo.DeleteOtherSessions(r.Context(), email, sessionId)
+	}
+	if err != nil {
+		log.Printf("Failed to end other sessions of '%s': %s", email, err)
+	}
+
+	w.Write([]byte("Пароль изменён"))
+}
+
+// RequestEmailChange отправляет на новую почту пользователя ссылку для подтверждения её смены.
+// Почта меняется только после перехода по ссылке. Для смены почты нужно указать пароль:
+//
+//	{"email": "new@mail.com", "password": "текущий пароль"}
+//
+// Обрабатывает POST запросы по пути '/users/me/email'.
+func (c *UsersController) RequestEmailChange(w http.ResponseWriter, r *http.Request) {
+	rawJwt := authorization.FromHeader(r.Header)
+	jwtClaims, err := authorization.GetClaims(rawJwt, jwtKey())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	email, err := jwtClaims.GetSubject()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	if !c.usersRepo.EmailExists(r.Context(), email) {
+		http.Error(w, errInvalidEmail.Error(), http.StatusForbidden)
+		return
+	}
+
+	type reqBody struct {
+		Email    string `json:"email"`
+		Password string `json:"password"`
+	}
+
+	body, err := readBody[reqBody](r.Body)
+	if err != nil {
+		http.Error(w, errReadingBody.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if body.Email == "" || body.Email == email {
+		http.Error(w, errInvalidEmail.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if !c.checkPassword(r.Context(), email, body.Password) {
+		http.Error(w, errWrongPassword.Error(), http.StatusForbidden)
+		return
+	}
+
+	// Адрес, на который уже начата регистрация, тоже считается занятым: иначе одно из подтверждений завершится ошибкой
+	if c.usersRepo.EmailExists(r.Context(), body.Email) || c.unverifiedUsersRepo.HasToken(body.Email) {
+		http.Error(w, "user with this email already exists", http.StatusForbidden)
+		return
+	}
+
+	confirmToken, err := c.emailChangesRepo.CreateEmailChange(r.Context(), email, body.Email, time.Now().Add(c.resetTTL()))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("error creating confirm token: %s", err), http.StatusInternalServerError)
+		return
+	}
+
+	// Ссылка для подтверждения новой почты
+	confirmLink := c.cfg.Host + ":" + c.cfg.Port + c.cfg.Prefix + "/users/me/email/confirm?t=" + confirmToken
+
+	log.Printf("Sending an email change confirmation link to '%s'...\n", body.Email)
+
+	const subject = "Friendly reminder"
+	text := fmt.Sprintf(
+		"Чтобы сменить почту своего аккаунта на этот адрес, перейдите по ссылке:\n%s\n\nЕсли вы не запрашивали это письмо, проигнорируйте его.",
+		confirmLink)
+
+	go c.emailSender.Send(
+		subject,
+		text,
+		body.Email)
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// ConfirmEmailChange является эндпоинтом, на который пользователь попадёт, подтверждая новую почту.
+// После смены почты все сессии пользователя завершаются, и нужно войти заново с новой почтой.
+//
+// Обрабатывает GET запросы по пути '/users/me/email/confirm'.
+func (c *UsersController) ConfirmEmailChange(w http.ResponseWriter, r *http.Request) {
+	token := r.URL.Query().Get("t")
+
+	oldEmail, newEmail, err := c.emailChangesRepo.ChangeEmail(r.Context(), token)
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "invalid or expired confirm token", http.StatusForbidden)
+		return
+	}
+	if errors.Is(err, models.ErrEmailTaken) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Уведомляем старый адрес, чтобы владелец заметил смену почты, если её сделал не он
+	go c.emailSender.Send(
+		"Friendly reminder",
+		fmt.Sprintf("Почта вашего аккаунта изменена на %s.", newEmail),
+		oldEmail)
+
+	w.Write([]byte("Почта изменена"))
+}
+
+// DeleteMe удаляет аккаунт пользователя вместе со всеми его списками и задачами. Для удаления нужно указать пароль:
+//
+//	{"password": "текущий пароль", "export": true}
+//
+// Если export равен true, перед удалением пользователю на почту отправляется выгрузка его списков, задач и комментариев в формате JSON.
+// Если выгрузку отправить не удалось, аккаунт не удаляется.
+//
+// Обрабатывает DELETE запросы по пути '/users/me'.
+func (c *UsersController) DeleteMe(w http.ResponseWriter, r *http.Request) {
+	rawJwt := authorization.FromHeader(r.Header)
+	jwtClaims, err := authorization.GetClaims(rawJwt, jwtKey())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	email, err := jwtClaims.GetSubject()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	if !c.usersRepo.EmailExists(r.Context(), email) {
+		http.Error(w, errInvalidEmail.Error(), http.StatusForbidden)
+		return
+	}
+
+	type reqBody struct {
+		Password string `json:"password"`
+		Export   bool   `json:"export"`
+	}
+
+	body, err := readBody[reqBody](r.Body)
+	if err != nil {
+		http.Error(w, errReadingBody.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if !c.checkPassword(r.Context(), email, body.Password) {
+		http.Error(w, errWrongPassword.Error(), http.StatusForbidden)
+		return
+	}
+
+	if body.Export {
+		export, err := c.usersRepo.ExportData(r.Context(), email)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		data, err := json.MarshalIndent(export, "", "  ")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		log.Printf("Sending data export to '%s'...\n", email)
+
+		// Письмо отправляется синхронно: аккаунт удаляется, только если выгрузка дошла
+		err = c.emailSender.Send("Friendly reminder: выгрузка ваших данных", string(data), email)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("failed to send data export: %s", err), http.StatusBadGateway)
+			return
+		}
+	}
+
+	err = c.usersRepo.DeleteUser(r.Context(), email)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// dummyHash - хэш argon2id произвольного пароля с теми же параметрами, что и у новых хэшей.
+// Login проверяет по нему пароль, если пользователь с указанной почтой не найден.
+const dummyHash = "$argon2id$v=19$m=19456,t=2,p=1$E1osNh0jB8vvjKYmTtH0SA$nsr6AIG5rVA0zxxlAKTm62srNSL3oYWXhNEeNU2u8PA"
+
+// checkPassword возвращает true, если password - пароль пользователя email.
+func (c *UsersController) checkPassword(ctx context.Context, email, password string) bool {
+	hash, err := c.usersRepo.GetPasswordHash(ctx, email)
+	return err == nil && hasher.Verify(password, hash)
+}
+
+// accessTTL возвращает срок действия access токена. Если он не задан в конфигурации, токен действует 15 минут.
+func (c *UsersController) accessTTL() time.Duration {
+	if c.cfg.AuthOptions.AccessTTL <= 0 {
+		return 15 * time.Minute
+	}
+	return c.cfg.AuthOptions.AccessTTL * time.Minute
+}
+
+// resetTTL возвращает срок действия ссылок для сброса пароля и смены почты.
+// Если он не задан в конфигурации, ссылка действует час.
+func (c *UsersController) resetTTL() time.Duration {
+	if c.cfg.AuthOptions.ResetTTL <= 0 {
+		return time.Hour
+	}
+	return c.cfg.AuthOptions.ResetTTL * time.Minute
+}
+
+// refreshTTL возвращает срок действия refresh токена. Если он не задан в конфигурации, токен действует 30 дней.
+func (c *UsersController) refreshTTL() time.Duration {
+	if c.cfg.AuthOptions.RefreshTTL <= 0 {
+		return 30 * 24 * time.Hour
+	}
+	return c.cfg.AuthOptions.RefreshTTL * time.Hour
 }
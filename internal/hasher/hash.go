@@ -1,9 +1,114 @@
 package hasher
 
-import "crypto/sha256"
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
 
-func Hash(data string) string {
-	h := sha256.New()
-	h.Write([]byte(data))
-	return string(h.Sum(nil))
+	"golang.org/x/crypto/argon2"
+)
+
+// Параметры argon2id для новых хэшей (рекомендация OWASP).
+// При их изменении хэши со старыми параметрами пересчитываются при следующем входе пользователя (см. NeedsRehash).
+const (
+	memory  = 19 * 1024 // КиБ
+	time    = 2
+	threads = 1
+	saltLen = 16
+	keyLen  = 32
+)
+
+// prefix - начало хэша в формате PHC: "$argon2id$v=19$m=19456,t=2,p=1$<соль>$<хэш>".
+// Хэши без этого префикса считаются старыми хэшами SHA-256 без соли.
+const prefix = "$argon2id$"
+
+var b64 = base64.RawStdEncoding
+
+// Hash возвращает хэш пароля argon2id со случайной солью. Параметры и соль записываются в сам хэш.
+func Hash(password string) string {
+	salt := make([]byte, saltLen)
+	rand.Read(salt)
+
+	key := argon2.IDKey([]byte(password), salt, time, memory, threads, keyLen)
+	return fmt.Sprintf("%sv=%d$m=%d,t=%d,p=%d$%s$%s",
+		prefix, argon2.Version, memory, time, threads, b64.EncodeToString(salt), b64.EncodeToString(key))
+}
+
+// Verify возвращает true, если пароль password соответствует хэшу encoded.
+// Поддерживаются хэши, созданные Hash, и старые хэши SHA-256 без соли.
+func Verify(password, encoded string) bool {
+	if !strings.HasPrefix(encoded, prefix) {
+		return subtle.ConstantTimeCompare([]byte(legacyHash(password)), []byte(encoded)) == 1
+	}
+
+	p, salt, key, err := decode(encoded)
+	if err != nil {
+		return false
+	}
+
+	other := argon2.IDKey([]byte(password), salt, p.time, p.memory, p.threads, uint32(len(key)))
+	return subtle.ConstantTimeCompare(key, other) == 1
+}
+
+// NeedsRehash возвращает true, если хэш encoded создан старым алгоритмом или с устаревшими параметрами
+// и пароль нужно захэшировать заново.
+func NeedsRehash(encoded string) bool {
+	p, salt, key, err := decode(encoded)
+	if err != nil {
+		return true
+	}
+
+	return p != (params{memory, time, threads}) || len(salt) != saltLen || len(key) != keyLen
+}
+
+type params struct {
+	memory  uint32
+	time    uint32
+	threads uint8
+}
+
+// decode разбирает хэш в формате PHC, созданный Hash.
+func decode(encoded string) (p params, salt, key []byte, err error) {
+	parts := strings.Split(strings.TrimPrefix(encoded, prefix), "$")
+	if !strings.HasPrefix(encoded, prefix) || len(parts) != 4 {
+		return p, nil, nil, errors.New("invalid hash format")
+	}
+
+	var version int
+	if _, err = fmt.Sscanf(parts[0], "v=%d", &version); err != nil {
+		return p, nil, nil, err
+	}
+	if version != argon2.Version {
+		return p, nil, nil, fmt.Errorf("unsupported argon2 version %d", version)
+	}
+
+	if _, err = fmt.Sscanf(parts[1], "m=%d,t=%d,p=%d", &p.memory, &p.time, &p.threads); err != nil {
+		return p, nil, nil, err
+	}
+	if p.time < 1 || p.threads < 1 {
+		return p, nil, nil, errors.New("invalid argon2 parameters")
+	}
+
+	if salt, err = b64.DecodeString(parts[2]); err != nil {
+		return p, nil, nil, err
+	}
+	if key, err = b64.DecodeString(parts[3]); err != nil {
+		return p, nil, nil, err
+	}
+	if len(key) == 0 {
+		return p, nil, nil, errors.New("empty hash")
+	}
+
+	return p, salt, key, nil
+}
+
+// legacyHash - прежний способ хэширования паролей, SHA-256 без соли.
+// Используется только для проверки паролей пользователей, которые ещё не входили после перехода на argon2id.
+func legacyHash(password string) string {
+	h := sha256.Sum256([]byte(password))
+	return string(h[:])
 }
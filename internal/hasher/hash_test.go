@@ -0,0 +1,52 @@
+package hasher
+
+import (
+	"crypto/sha256"
+	"strings"
+	"testing"
+)
+
+const (
+	pwd      = "password4321"
+	otherPwd = "password1234"
+)
+
+func TestHash(t *testing.T) {
+	hash := Hash(pwd)
+	if !strings.HasPrefix(hash, "$argon2id$") {
+		t.Fatalf("Wanted argon2id hash, got %q", hash)
+	}
+
+	if hash == Hash(pwd) {
+		t.Fatal("Hashes of the same password are equal, salt is not used")
+	}
+
+	if !Verify(pwd, hash) {
+		t.Fatal("Password doesn't match its hash")
+	}
+
+	if Verify(otherPwd, hash) {
+		t.Fatal("Wrong password matches the hash")
+	}
+
+	if NeedsRehash(hash) {
+		t.Fatal("New hash needs rehash")
+	}
+}
+
+func TestHash_Legacy(t *testing.T) {
+	sum := sha256.Sum256([]byte(pwd))
+	legacy := string(sum[:])
+
+	if !Verify(pwd, legacy) {
+		t.Fatal("Password doesn't match its legacy hash")
+	}
+
+	if Verify(otherPwd, legacy) {
+		t.Fatal("Wrong password matches the legacy hash")
+	}
+
+	if !NeedsRehash(legacy) {
+		t.Fatal("Legacy hash doesn't need rehash")
+	}
+}
@@ -0,0 +1,24 @@
+package models
+
+// BatchOp - вид операции в пакетном запросе.
+type BatchOp string
+
+const (
+	BatchCreate   BatchOp = "create"   // Создать задачу Task
+	BatchComplete BatchOp = "complete" // Отметить задачу TaskId выполненной (или невыполненной, если Done = false)
+	BatchDelete   BatchOp = "delete"   // Переместить задачу TaskId в корзину
+)
+
+// BatchOperation - одна операция пакетного запроса.
+type BatchOperation struct {
+	Op     BatchOp `json:"op"`
+	TaskId int64   `json:"task_id,omitempty"`
+	Done   *bool   `json:"done,omitempty"` // По умолчанию true
+	Task   *Task   `json:"task,omitempty"`
+}
+
+// BatchResult - результат одной операции пакетного запроса.
+type BatchResult struct {
+	Task *Task // Task - созданная задача или следующее повторение выполненной повторяющейся задачи.
+	Err  error // Err - ошибка, из-за которой операция не выполнена.
+}
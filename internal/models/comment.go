@@ -0,0 +1,12 @@
+package models
+
+import "time"
+
+// Comment - комментарий к задаче.
+type Comment struct {
+	Id        int64     `json:"comment_id"`
+	TaskId    int64     `json:"task_id"`
+	UserEmail string    `json:"user_email"` // Автор комментария
+	Text      string    `json:"text"`
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -0,0 +1,30 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// TaskEventType - вид события в истории задачи.
+type TaskEventType string
+
+const (
+	EventCreated   TaskEventType = "created"
+	EventEdited    TaskEventType = "edited"
+	EventCompleted TaskEventType = "completed"
+	EventReopened  TaskEventType = "reopened" // Выполненная задача снова отмечена невыполненной
+	EventDeleted   TaskEventType = "deleted"  // Задача перемещена в корзину
+	EventRestored  TaskEventType = "restored" // Задача возвращена из корзины
+)
+
+// TaskEvent - запись в истории изменений задачи.
+type TaskEvent struct {
+	Id        int64         `json:"event_id"`
+	TaskId    int64         `json:"task_id"`
+	UserEmail string        `json:"user_email"` // UserEmail - пользователь, совершивший действие. Пусто, если его аккаунт удалён.
+	Type      TaskEventType `json:"type"`
+
+	// Details - подробности события, например изменённые поля задачи. Пусто, если подробностей нет.
+	Details   json.RawMessage `json:"details,omitempty"`
+	CreatedAt time.Time       `json:"created_at"`
+}
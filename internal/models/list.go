@@ -0,0 +1,41 @@
+package models
+
+// List - это именованный список задач пользователя (например, "Работа" или "Дом").
+//
+// Задачи, не привязанные ни к одному списку, относятся к списку пользователя по умолчанию.
+// Владелец может открыть доступ к списку другим пользователям (см. ListMember).
+type List struct {
+	Id        int64    `json:"list_id"`
+	UserEmail string   `json:"user_email"` // Владелец списка
+	Name      string   `json:"name"`
+	Digest    bool     `json:"digest"` // Digest равен true, если задачи из списка присылаются пользователю в рассылке.
+	Role      ListRole `json:"role"`   // Роль пользователя, запросившего список
+}
+
+// ListPatch описывает частичное изменение списка. Nil поля не меняются.
+type ListPatch struct {
+	Name   *string `json:"name"`
+	Digest *bool   `json:"digest"`
+}
+
+// ListRole - роль пользователя в списке.
+type ListRole string
+
+const (
+	RoleOwner  ListRole = "owner"  // Владелец: полный доступ к списку и его участникам
+	RoleEditor ListRole = "editor" // Редактор: может добавлять, изменять и удалять задачи списка
+	RoleViewer ListRole = "viewer" // Читатель: может только просматривать задачи списка
+)
+
+// Valid возвращает true, если роль можно выдать участнику списка.
+func (r ListRole) Valid() bool {
+	return r == RoleEditor || r == RoleViewer
+}
+
+// ListMember - участник общего списка.
+type ListMember struct {
+	ListId    int64    `json:"list_id"`
+	UserEmail string   `json:"user_email"`
+	Role      ListRole `json:"role"`
+	Accepted  bool     `json:"accepted"` // Accepted равен true, если пользователь принял приглашение.
+}
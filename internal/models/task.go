@@ -1,8 +1,212 @@
 package models
 
+import (
+	"encoding/json"
+	"time"
+)
+
 // Task - это задача, входящая в список пользователя.
 type Task struct {
-	Id        int64  `json:"task_id"`
-	UserEmail string `json:"user_email"`
-	Value     string `json:"value"`
+	Id        int64      `json:"task_id"`
+	UserEmail string     `json:"user_email"`
+	ListId    *int64     `json:"list_id,omitempty"` // ListId - id списка, в который входит задача. Равен nil для списка по умолчанию.
+	Value     string     `json:"value"`
+	Notes     string     `json:"notes,omitempty"`     // Notes - подробное описание задачи в формате Markdown.
+	DueDate   *time.Time `json:"due_date,omitempty"`  // DueDate - срок выполнения задачи. Равен nil, если срок не указан.
+	RemindAt  *time.Time `json:"remind_at,omitempty"` // RemindAt - время, в которое пользователю придёт напоминание о задаче. Равен nil, если напоминание не нужно.
+
+	// RemindEmail - почта пользователя, которому придёт напоминание: того, кто его установил, а если у него больше нет
+	// доступа к задаче - её владельца. Пустая, если напоминание не нужно.
+	RemindEmail string `json:"remind_email,omitempty"`
+
+	Completed   bool       `json:"completed"`              // Completed равен true, если задача выполнена.
+	CompletedAt *time.Time `json:"completed_at,omitempty"` // CompletedAt - время выполнения задачи. Равен nil, если задача не выполнена.
+
+	Priority Priority `json:"priority"`
+	Position int64    `json:"position"`       // Position - место задачи в списке, заданное пользователем.
+	Tags     []string `json:"tags,omitempty"` // Tags - метки задачи в алфавитном порядке.
+
+	Items []TaskItem `json:"items,omitempty"` // Items - пункты чек-листа задачи в порядке их добавления.
+
+	// Recurrence - правило повторения задачи в формате RRULE, например 'FREQ=WEEKLY;BYDAY=MO'.
+	// Пустое, если задача не повторяется.
+	Recurrence string    `json:"recurrence,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+
+	DeletedAt *time.Time `json:"deleted_at,omitempty"` // DeletedAt - время перемещения задачи в корзину. Равен nil, если задача не удалена.
+}
+
+// TaskItem - пункт чек-листа внутри задачи.
+type TaskItem struct {
+	Id       int64  `json:"item_id"`
+	TaskId   int64  `json:"task_id"`
+	Value    string `json:"value"`
+	Done     bool   `json:"done"`
+	Position int64  `json:"position"`
+}
+
+// TaskItemPatch описывает частичное изменение пункта чек-листа. Поля, отсутствующие в запросе, не меняются.
+type TaskItemPatch struct {
+	Value *string `json:"value"`
+	Done  *bool   `json:"done"`
+}
+
+// Empty возвращает true, если изменение не затрагивает ни одного поля.
+func (p TaskItemPatch) Empty() bool {
+	return p.Value == nil && p.Done == nil
+}
+
+// Priority - приоритет задачи. Задачи с большим приоритетом идут в списке раньше.
+type Priority int
+
+const (
+	PriorityLow    Priority = -1
+	PriorityNormal Priority = 0
+	PriorityHigh   Priority = 1
+)
+
+// Valid возвращает true, если приоритет имеет одно из допустимых значений.
+func (p Priority) Valid() bool {
+	return p >= PriorityLow && p <= PriorityHigh
+}
+
+// DueFilter ограничивает выборку задач по сроку выполнения.
+type DueFilter string
+
+const (
+	DueAny     DueFilter = ""        // Без ограничений по сроку
+	DueOverdue DueFilter = "overdue" // Просроченные задачи
+	DueToday   DueFilter = "today"   // Задачи со сроком на сегодня
+	DueWeek    DueFilter = "week"    // Задачи со сроком на текущей неделе
+)
+
+// Valid возвращает true, если фильтр имеет одно из допустимых значений.
+func (f DueFilter) Valid() bool {
+	switch f {
+	case DueAny, DueOverdue, DueToday, DueWeek:
+		return true
+	}
+	return false
+}
+
+// TaskSort - порядок сортировки задач в списке.
+type TaskSort string
+
+const (
+	SortPriority TaskSort = "priority" // По приоритету, а затем по времени создания. Используется по умолчанию
+	SortPosition TaskSort = "position" // В порядке, заданном пользователем
+	SortDue      TaskSort = "due"      // По сроку выполнения; задачи без срока идут в конце
+	SortCreated  TaskSort = "created"  // По времени создания, сначала новые
+)
+
+// Valid возвращает true, если порядок сортировки имеет одно из допустимых значений.
+func (s TaskSort) Valid() bool {
+	switch s {
+	case "", SortPriority, SortPosition, SortDue, SortCreated:
+		return true
+	}
+	return false
+}
+
+// ListOptions задаёт параметры выборки списка задач.
+type ListOptions struct {
+	Due      DueFilter
+	Location *time.Location // Часовой пояс, в котором вычисляются границы дня и недели для Due. nil - часовой пояс сервера.
+	Sort     TaskSort
+
+	IncludeCompleted bool  // Если true, в выборку попадают и выполненные задачи.
+	Completed        *bool // Если не nil, в выборку попадают только выполненные (true) или только невыполненные (false) задачи.
+
+	// Границы полуинтервалов [from, to) для времени создания и срока выполнения задачи. nil - граница не нужна.
+	CreatedFrom, CreatedTo *time.Time
+	DueFrom, DueTo         *time.Time
+
+	Limit int         // Если больше нуля, выбирается не больше Limit задач.
+	After *ListCursor // Если не nil, выбираются только задачи, идущие в порядке Sort после указанной.
+
+	ListId     *int64 // Если не nil, в выборку попадают только задачи из указанного списка.
+	Tag        string // Если не пустая, в выборку попадают только задачи с указанной меткой.
+	DigestOnly bool   // Если true, в выборку попадают только задачи из списков, выбранных пользователем для рассылки.
+}
+
+// SearchQuery задаёт параметры полнотекстового поиска задач.
+type SearchQuery struct {
+	Text   string // Text - строка поиска. Поддерживаются кавычки для поиска фраз, "or" и "-" для исключения слов.
+	Limit  int
+	Offset int
+
+	IncludeCompleted bool // Если true, в выборку попадают и выполненные задачи.
+}
+
+// SearchResult - страница результатов поиска. Задачи упорядочены по релевантности.
+type SearchResult struct {
+	Tasks []Task `json:"tasks"`
+	Total int64  `json:"total"` // Total - количество найденных задач на всех страницах.
+}
+
+// ListCursor - положение задачи в списке, отсортированном в порядке Sort.
+// Хранит значения всех полей, по которым сортируется список, чтобы следующая страница
+// продолжалась с того же места, даже если между запросами в список добавляются задачи.
+type ListCursor struct {
+	Sort      TaskSort   `json:"s"`
+	Position  int64      `json:"p"`
+	Priority  Priority   `json:"pr"`
+	DueDate   *time.Time `json:"d,omitempty"`
+	CreatedAt time.Time  `json:"c"`
+	Id        int64      `json:"id"`
+}
+
+// CursorAt возвращает положение задачи t в списке, отсортированном в порядке sort.
+func CursorAt(t Task, sort TaskSort) ListCursor {
+	return ListCursor{
+		Sort:      sort,
+		Position:  t.Position,
+		Priority:  t.Priority,
+		DueDate:   t.DueDate,
+		CreatedAt: t.CreatedAt,
+		Id:        t.Id,
+	}
+}
+
+// TaskPage - страница списка задач.
+type TaskPage struct {
+	Tasks      []Task `json:"tasks"`
+	NextCursor string `json:"next_cursor,omitempty"` // NextCursor передаётся в следующий запрос, чтобы получить следующую страницу. Пуст на последней странице.
+}
+
+// TaskPatch описывает частичное изменение задачи. Поля, отсутствующие в запросе, не меняются.
+type TaskPatch struct {
+	Value      *string             `json:"value"`
+	Notes      *string             `json:"notes"`
+	DueDate    Nullable[time.Time] `json:"due_date"`
+	RemindAt   Nullable[time.Time] `json:"remind_at"`
+	ListId     Nullable[int64]     `json:"list_id"` // null переносит задачу в список по умолчанию
+	Priority   *Priority           `json:"priority"`
+	Recurrence *string             `json:"recurrence"` // пустая строка отключает повторение
+}
+
+// Empty возвращает true, если изменение не затрагивает ни одного поля.
+func (p TaskPatch) Empty() bool {
+	return p.Value == nil && p.Notes == nil && !p.DueDate.Set && !p.RemindAt.Set && !p.ListId.Set && p.Priority == nil && p.Recurrence == nil
+}
+
+// Nullable - поле частичного изменения, которое можно не только изменить, но и сбросить, передав null.
+type Nullable[T any] struct {
+	Set   bool // Set равен true, если поле присутствует в запросе.
+	Value *T   // Value равен nil, если поле нужно сбросить.
+}
+
+func (n *Nullable[T]) UnmarshalJSON(b []byte) error {
+	n.Set = true
+	if string(b) == "null" {
+		n.Value = nil
+		return nil
+	}
+
+	var v T
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	n.Value = &v
+	return nil
 }
@@ -1,7 +1,53 @@
 package models
 
+import (
+	"errors"
+	"time"
+)
+
+// ErrEmailTaken возвращается, если почта, которую пользователь подтверждает, уже занята другим пользователем.
+var ErrEmailTaken = errors.New("user with this email already exists")
+
 type User struct {
 	Email      string `json:"email"`
 	Password   string `json:"password"`
 	Subscribed bool   `json:"subscribed"` // Subscribed будет равным true, если пользователь подписан на рассылку; иначе false.
+
+	// DigestSchedule - cron выражение, по которому пользователю присылается список дел.
+	// Если пустое, список присылается с интервалом по умолчанию.
+	DigestSchedule string `json:"digest_schedule,omitempty"`
+
+	// TimeZone - часовой пояс IANA (например, 'Europe/Moscow'), в котором вычисляется расписание DigestSchedule.
+	TimeZone string `json:"time_zone,omitempty"`
+
+	// DigestGroup - способ группировки задач в рассылке.
+	DigestGroup DigestGroup `json:"digest_group,omitempty"`
+
+	// NextDigestAt - время следующей отправки списка дел. Равно nil, если отправка ещё не запланирована.
+	NextDigestAt *time.Time `json:"-"`
+
+	// LastDigestAt - время последней отправки списка дел. Равно nil, если список ещё не отправлялся.
+	LastDigestAt *time.Time `json:"-"`
+}
+
+// UserExport - выгрузка данных пользователя, которая отправляется ему перед удалением аккаунта.
+type UserExport struct {
+	Email      string    `json:"email"`
+	ExportedAt time.Time `json:"exported_at"`
+	Lists      []List    `json:"lists"`
+	Tasks      []Task    `json:"tasks"`    // Все задачи пользователя, включая выполненные и удалённые в корзину
+	Comments   []Comment `json:"comments"` // Комментарии пользователя, в том числе к задачам других пользователей
+}
+
+// DigestGroup - способ группировки задач в письме со списком дел.
+type DigestGroup string
+
+const (
+	DigestGroupNone DigestGroup = ""    // Задачи идут одним списком
+	DigestGroupTag  DigestGroup = "tag" // Задачи сгруппированы по меткам
+)
+
+// Valid возвращает true, если способ группировки имеет одно из допустимых значений.
+func (g DigestGroup) Valid() bool {
+	return g == DigestGroupNone || g == DigestGroupTag
 }
@@ -2,58 +2,151 @@ package reminder
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/artemwebber1/friendly_reminder/internal/models"
+	"github.com/artemwebber1/friendly_reminder/pkg/cron"
 	"github.com/artemwebber1/friendly_reminder/pkg/email"
+	"github.com/artemwebber1/friendly_reminder/pkg/markdown"
 )
 
 // Reminder представляет собой объект, который в отдельной горутине
 // присылает уведомления пользователям, подписанным на рассылку.
 type Reminder interface {
-	// StartSending в достаёт из базы данных электронные почты всех пользователей,
-	// подписанных на рассылку, и отправляет им их списки дел c указанным интервалом.
+	// StartSending отправляет пользователям, подписанным на рассылку, их списки дел.
+	// Каждому пользователю список приходит по его собственному расписанию,
+	// а если расписание не задано - с указанным интервалом d.
 	StartSending(ctx context.Context, d time.Duration)
+
+	// StartReminding с указанным интервалом проверяет, не наступило ли время напоминаний о задачах,
+	// и отправляет пользователям письма о каждой такой задаче.
+	StartReminding(ctx context.Context, d time.Duration)
 }
 
 type tasksRepository interface {
-	GetList(ctx context.Context, userEmail string) ([]models.Task, error)
+	GetList(ctx context.Context, userEmail string, opts models.ListOptions) ([]models.Task, error)
+	GetDueReminders(ctx context.Context, now time.Time) ([]models.Task, error)
+	MarkReminderSent(ctx context.Context, id int64) error
 }
 
 type usersRepository interface {
-	GetEmailsSubscribed(ctx context.Context) ([]string, error)
+	GetDigestsDue(ctx context.Context, now time.Time) ([]models.User, error)
+	SetNextDigest(ctx context.Context, email string, t time.Time) error
+	SetLastDigest(ctx context.Context, email string, t time.Time) error
 	Subscribe(ctx context.Context, email string, subscr bool) error
+	GetByEmail(ctx context.Context, email string) (*models.User, error)
+}
+
+type commentsRepository interface {
+	CountNewComments(ctx context.Context, userEmail string, since time.Time) (map[int64]int, error)
 }
 
+// checkInterval - интервал, с которым проверяется, кому из пользователей пора отправить список дел.
+// Совпадает с точностью cron расписаний.
+const checkInterval = time.Minute
+
 type defaultReminder struct {
-	sender    email.Sender // Для отправки электронных писем
-	usersRepo usersRepository
-	tasksRepo tasksRepository
+	sender       email.Sender // Для отправки электронных писем
+	usersRepo    usersRepository
+	tasksRepo    tasksRepository
+	commentsRepo commentsRepository
 }
 
-func New(s email.Sender, ur usersRepository, tr tasksRepository) Reminder {
+func New(s email.Sender, ur usersRepository, tr tasksRepository, cr commentsRepository) Reminder {
 	return &defaultReminder{
-		sender:    s,
-		usersRepo: ur,
-		tasksRepo: tr,
+		sender:       s,
+		usersRepo:    ur,
+		tasksRepo:    tr,
+		commentsRepo: cr,
 	}
 }
 
-// StartSending в достаёт из базы данных электронные почты всех пользователей,
-// подписанных на рассылку, и отправляет им их списки дел c указанным интервалом.
+// StartSending отправляет пользователям, подписанным на рассылку, их списки дел.
+// Каждому пользователю список приходит по его собственному расписанию,
+// а если расписание не задано - с указанным интервалом d.
+//
+// Время следующей отправки хранится в базе данных. Если оно ещё не запланировано
+// (пользователь только что подписался или сменил расписание), оно вычисляется без отправки письма.
 func (s *defaultReminder) StartSending(ctx context.Context, d time.Duration) {
 	for {
-		log.Println("Sending emails")
-		emails, err := s.usersRepo.GetEmailsSubscribed(ctx)
+		now := time.Now()
+		users, err := s.usersRepo.GetDigestsDue(ctx, now)
 		if err != nil {
 			log.Println(err)
+		}
+
+		for _, u := range users {
+			next, err := NextDigest(u, now, d)
+			if err != nil {
+				log.Printf("Invalid digest schedule for '%s': %s", u.Email, err)
+				continue
+			}
+
+			if u.NextDigestAt != nil {
+				log.Printf("Sending list to '%s'", u.Email)
+				go s.sendList(ctx, u)
+			}
+
+			if err = s.usersRepo.SetNextDigest(ctx, u.Email, next); err != nil {
+				log.Println(err)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
 			return
+		case <-time.After(checkInterval):
+			continue
+		}
+	}
+}
+
+// NextDigest возвращает время, когда пользователю u нужно отправить список дел, если последняя отправка была в момент now.
+// Если у пользователя не задано расписание, возвращается now + d.
+func NextDigest(u models.User, now time.Time, d time.Duration) (time.Time, error) {
+	if u.DigestSchedule == "" {
+		return now.Add(d), nil
+	}
+
+	sched, err := cron.Parse(u.DigestSchedule)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	loc, err := time.LoadLocation(u.TimeZone)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	next := sched.Next(now.In(loc))
+	if next.IsZero() {
+		return time.Time{}, errors.New("schedule never fires")
+	}
+
+	return next, nil
+}
+
+// StartReminding с указанным интервалом проверяет, не наступило ли время напоминаний о задачах,
+// и отправляет пользователям письма о каждой такой задаче.
+//
+// Расписание напоминаний хранится в базе данных, поэтому напоминания,
+// время которых наступило, пока приложение было остановлено, будут отправлены после запуска.
+func (s *defaultReminder) StartReminding(ctx context.Context, d time.Duration) {
+	for {
+		tasks, err := s.tasksRepo.GetDueReminders(ctx, time.Now())
+		if err != nil {
+			log.Println(err)
 		}
 
-		for _, email := range emails {
-			go s.sendList(ctx, email)
+		for _, task := range tasks {
+			s.sendTaskReminder(ctx, task)
 		}
 
 		select {
@@ -65,21 +158,60 @@ func (s *defaultReminder) StartSending(ctx context.Context, d time.Duration) {
 	}
 }
 
-func (s *defaultReminder) sendList(ctx context.Context, email string) {
+func (s *defaultReminder) sendTaskReminder(ctx context.Context, task models.Task) {
+	subject := "Friendly reminder: " + task.Value
+	body := fmt.Sprintf("Напоминаем о задаче из вашего списка дел:\n%s", task.Value)
+	if task.DueDate != nil {
+		// Срок выводится в часовом поясе получателя
+		loc := time.UTC
+		if u, err := s.usersRepo.GetByEmail(ctx, task.RemindEmail); err != nil {
+			log.Println(err)
+		} else {
+			loc = location(u.TimeZone)
+		}
+		body += fmt.Sprintf("\n\nСрок выполнения: %s", formatDate(*task.DueDate, loc))
+	}
+
+	if err := s.sender.Send(subject, body, task.RemindEmail); err != nil {
+		// Напоминание не помечается как отправленное, поэтому попытка повторится при следующей проверке
+		log.Println(err)
+		return
+	}
+
+	if err := s.tasksRepo.MarkReminderSent(ctx, task.Id); err != nil {
+		log.Println(err)
+	}
+}
+
+func (s *defaultReminder) sendList(ctx context.Context, u models.User) {
+	email := u.Email
+	now := time.Now()
+
 	// Получаем список пользователя
-	list, err := s.tasksRepo.GetList(ctx, email)
+	list, err := s.tasksRepo.GetList(ctx, email, models.ListOptions{DigestOnly: true, Sort: models.SortPosition})
 	if err != nil {
 		log.Println(err)
 		return
 	}
 
-	// Преобразуем слайс list в строку вида:
-	// 1. Задача 1
-	// 2. Задача 2
-	// ...
-	body := ""
-	for i, item := range list {
-		body += fmt.Sprintf("\n%d. %s", i+1, item.Value)
+	loc := location(u.TimeZone)
+
+	var body string
+	switch u.DigestGroup {
+	case models.DigestGroupTag:
+		body = formatByTag(list, loc)
+	default:
+		body = formatList(list, loc)
+	}
+
+	// Задачи, прокомментированные другими участниками после прошлой рассылки
+	if u.LastDigestAt != nil && len(list) > 0 {
+		counts, err := s.commentsRepo.CountNewComments(ctx, email, *u.LastDigestAt)
+		if err != nil {
+			log.Println(err)
+		} else {
+			body += formatNewComments(list, counts)
+		}
 	}
 
 	subject := "Friendly reminder: ваш список дел"
@@ -95,4 +227,134 @@ func (s *defaultReminder) sendList(ctx context.Context, email string) {
 		log.Println(err)
 		return
 	}
+
+	if err = s.usersRepo.SetLastDigest(ctx, email, now); err != nil {
+		log.Println(err)
+	}
+}
+
+// location возвращает часовой пояс пользователя с названием timeZone.
+// Если часовой пояс не задан или неизвестен, возвращает UTC.
+func location(timeZone string) *time.Location {
+	loc, err := time.LoadLocation(timeZone)
+	if err != nil {
+		log.Printf("Invalid time zone '%s': %s", timeZone, err)
+		return time.UTC
+	}
+	return loc
+}
+
+// formatDate возвращает время t в часовом поясе loc в формате "02.01.2006 15:04".
+func formatDate(t time.Time, loc *time.Location) string {
+	return t.In(loc).Format("02.01.2006 15:04")
+}
+
+// formatNewComments возвращает строку со списком задач, к которым есть новые комментарии, и их количеством:
+//
+//	Новые комментарии: Купить молоко (2), Позвонить маме (1)
+//
+// counts - количество новых комментариев по id задачи. Если новых комментариев нет, возвращает пустую строку.
+func formatNewComments(list []models.Task, counts map[int64]int) string {
+	parts := make([]string, 0, len(counts))
+	for _, task := range list {
+		if n := counts[task.Id]; n > 0 {
+			parts = append(parts, fmt.Sprintf("%s (%d)", task.Value, n))
+		}
+	}
+
+	if len(parts) == 0 {
+		return ""
+	}
+	return "\n\nНовые комментарии: " + strings.Join(parts, ", ")
+}
+
+// formatList преобразует список задач в пронумерованный список, по задаче на строке.
+// Задачи с высоким приоритетом отмечаются знаком "(!)", у задач со сроком выполнения указывается срок в часовом поясе loc.
+// Описание задачи выводится под ней с отступом простым текстом, без разметки Markdown.
+// Пункты чек-листа выводятся с отступом под задачей и отмечаются "[x]", если выполнены, или "[ ]", если нет.
+// Рядом с такой задачей указывается, сколько её пунктов выполнено, например "(2/5)".
+func formatList(list []models.Task, loc *time.Location) string {
+	body := ""
+	for i, item := range list {
+		body += formatTask(i+1, item, loc)
+	}
+	return body
+}
+
+// formatByTag преобразует список задач в строку, в которой задачи сгруппированы по меткам:
+//
+//	#дом
+//	1. Задача 1
+//
+//	#работа
+//	1. Задача 2
+//
+//	Без меток
+//	1. Задача 3
+//
+// Задача с несколькими метками попадает в каждую из групп. Группы идут в алфавитном порядке.
+func formatByTag(list []models.Task, loc *time.Location) string {
+	groups := make(map[string][]models.Task)
+	untagged := make([]models.Task, 0)
+	for _, item := range list {
+		if len(item.Tags) == 0 {
+			untagged = append(untagged, item)
+			continue
+		}
+		for _, tag := range item.Tags {
+			groups[tag] = append(groups[tag], item)
+		}
+	}
+
+	tags := slices.Sorted(maps.Keys(groups))
+
+	body := ""
+	for _, tag := range tags {
+		body += "\n#" + tag + formatList(groups[tag], loc) + "\n"
+	}
+
+	if len(untagged) > 0 {
+		if body != "" {
+			body += "\nБез меток"
+		}
+		body += formatList(untagged, loc)
+	}
+
+	return body
+}
+
+func formatTask(n int, item models.Task, loc *time.Location) string {
+	mark := ""
+	if item.Priority == models.PriorityHigh {
+		mark = "(!) "
+	}
+
+	s := fmt.Sprintf("\n%d. %s%s", n, mark, item.Value)
+	if len(item.Items) > 0 {
+		done := 0
+		for _, it := range item.Items {
+			if it.Done {
+				done++
+			}
+		}
+		s += fmt.Sprintf(" (%d/%d)", done, len(item.Items))
+	}
+	if item.DueDate != nil {
+		s += fmt.Sprintf(" (до %s)", formatDate(*item.DueDate, loc))
+	}
+
+	indent := strings.Repeat(" ", len(strconv.Itoa(n))+2)
+	if item.Notes != "" {
+		for _, line := range strings.Split(markdown.ToText(item.Notes), "\n") {
+			s += "\n" + strings.TrimRight(indent+line, " ")
+		}
+	}
+	for _, it := range item.Items {
+		check := "[ ]"
+		if it.Done {
+			check = "[x]"
+		}
+		s += fmt.Sprintf("\n%s%s %s", indent, check, it.Value)
+	}
+	return s
 }
@@ -0,0 +1,123 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+	"time"
+
+	"github.com/artemwebber1/friendly_reminder/internal/models"
+)
+
+type CommentsRepository struct {
+	mu sync.Mutex
+	db *sql.DB
+}
+
+func NewCommentsRepository(db *sql.DB) *CommentsRepository {
+	return &CommentsRepository{
+		db: db,
+		mu: sync.Mutex{},
+	}
+}
+
+// AddComment добавляет комментарий пользователя comment.UserEmail к задаче comment.TaskId и возвращает его.
+// Комментировать задачу может любой, кому она доступна для просмотра.
+// Если такой задачи у пользователя нет или она в корзине, возвращает sql.ErrNoRows.
+func (r *CommentsRepository) AddComment(ctx context.Context, comment models.Comment) (models.Comment, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	row := r.db.QueryRowContext(
+		ctx,
+		`INSERT INTO task_comments(task_id, user_email, text)
+		SELECT task_id, $2, $3 FROM tasks WHERE task_id = $1 AND `+taskReadableBy("$2")+` AND deleted_at IS NULL
+		RETURNING comment_id, created_at`,
+		comment.TaskId, comment.UserEmail, comment.Text)
+
+	err := row.Scan(&comment.Id, &comment.CreatedAt)
+	if err != nil {
+		return models.Comment{}, err
+	}
+
+	return comment, nil
+}
+
+// GetComments возвращает комментарии к задаче с указанным id в порядке их добавления.
+// Если такой задачи у пользователя userEmail нет или она в корзине, возвращает sql.ErrNoRows.
+func (r *CommentsRepository) GetComments(ctx context.Context, taskId int64, userEmail string) ([]models.Comment, error) {
+	err := checkTaskAccess(ctx, r.db, taskId, userEmail, false)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := r.db.QueryContext(
+		ctx,
+		"SELECT comment_id, task_id, user_email, text, created_at FROM task_comments WHERE task_id = $1 ORDER BY comment_id",
+		taskId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	comments := make([]models.Comment, 0)
+	for rows.Next() {
+		var c models.Comment
+		err = rows.Scan(&c.Id, &c.TaskId, &c.UserEmail, &c.Text, &c.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		comments = append(comments, c)
+	}
+
+	return comments, rows.Err()
+}
+
+// DeleteComment удаляет комментарий с указанным id к задаче taskId. Удалить комментарий может только его автор.
+// Если такого комментария у пользователя userEmail нет, возвращает sql.ErrNoRows.
+func (r *CommentsRepository) DeleteComment(ctx context.Context, taskId, id int64, userEmail string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	res, err := r.db.ExecContext(
+		ctx,
+		"DELETE FROM task_comments WHERE comment_id = $1 AND task_id = $2 AND user_email = $3",
+		id, taskId, userEmail)
+	if err != nil {
+		return err
+	}
+
+	return checkAffected(res)
+}
+
+// CountNewComments возвращает количество комментариев, оставленных другими пользователями после момента since
+// к доступным пользователю userEmail задачам, не находящимся в корзине. Ключ словаря - id задачи; задачи без новых комментариев в него не попадают.
+func (r *CommentsRepository) CountNewComments(ctx context.Context, userEmail string, since time.Time) (map[int64]int, error) {
+	rows, err := r.db.QueryContext(
+		ctx,
+		`SELECT task_comments.task_id, COUNT(*) FROM task_comments
+		JOIN tasks ON tasks.task_id = task_comments.task_id
+		WHERE `+taskReadableBy("$1")+` AND tasks.deleted_at IS NULL
+			AND task_comments.user_email <> $1 AND task_comments.created_at > $2
+		GROUP BY task_comments.task_id`,
+		userEmail, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := make(map[int64]int)
+	for rows.Next() {
+		var (
+			taskId int64
+			n      int
+		)
+		err = rows.Scan(&taskId, &n)
+		if err != nil {
+			return nil, err
+		}
+		counts[taskId] = n
+	}
+
+	return counts, rows.Err()
+}
@@ -0,0 +1,122 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+	"time"
+
+	"github.com/artemwebber1/friendly_reminder/internal/models"
+	"github.com/artemwebber1/friendly_reminder/pkg/authorization"
+)
+
+type EmailChangesRepository struct {
+	mu sync.Mutex
+	db *sql.DB
+}
+
+func NewEmailChangesRepository(db *sql.DB) *EmailChangesRepository {
+	return &EmailChangesRepository{
+		db: db,
+		mu: sync.Mutex{},
+	}
+}
+
+// CreateEmailChange создаёт запрос на смену почты пользователя email на newEmail, действующий до момента expiresAt,
+// и возвращает токен для его подтверждения. Предыдущие запросы пользователя при этом перестают действовать.
+func (r *EmailChangesRepository) CreateEmailChange(ctx context.Context, email, newEmail string, expiresAt time.Time) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, "DELETE FROM email_changes WHERE user_email = $1", email)
+	if err != nil {
+		return "", err
+	}
+
+	token := generateToken()
+	_, err = tx.ExecContext(
+		ctx,
+		"INSERT INTO email_changes(token_hash, user_email, new_email, expires_at) VALUES($1, $2, $3, $4)",
+		authorization.HashToken(token), email, newEmail, expiresAt)
+	if err != nil {
+		return "", err
+	}
+
+	return token, tx.Commit()
+}
+
+// HasPendingChange возвращает true, если есть действующий запрос на смену почты какого-либо пользователя на email.
+func (r *EmailChangesRepository) HasPendingChange(ctx context.Context, email string) bool {
+	row := r.db.QueryRowContext(ctx, "SELECT 1 FROM email_changes WHERE new_email = $1 AND expires_at > now() LIMIT 1", email)
+	return row.Scan() != sql.ErrNoRows
+}
+
+// ChangeEmail меняет почту пользователя по токену подтверждения и возвращает старую и новую почту.
+// Вместе с почтой пользователя меняется почта владельца его задач и автора событий в их истории,
+// а все сессии пользователя завершаются. Токен после этого перестаёт действовать.
+// Регистрация на новую почту, которая ещё не подтверждена, при этом отменяется.
+// Если токен не существует или истёк, возвращает sql.ErrNoRows, а если новая почта уже занята - models.ErrEmailTaken.
+func (r *EmailChangesRepository) ChangeEmail(ctx context.Context, token string) (string, string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return "", "", err
+	}
+	defer tx.Rollback()
+
+	var oldEmail, newEmail string
+	err = tx.QueryRowContext(
+		ctx,
+		"DELETE FROM email_changes WHERE token_hash = $1 AND expires_at > now() RETURNING user_email, new_email",
+		authorization.HashToken(token)).Scan(&oldEmail, &newEmail)
+	if err != nil {
+		return "", "", err
+	}
+
+	// Списки, участие в них, комментарии и сессии обновляются каскадно.
+	// Если новую почту заняли, пока запрос ждал подтверждения, обновление нарушит уникальность users.email
+	res, err := tx.ExecContext(ctx, "UPDATE users SET email = $2 WHERE email = $1", oldEmail, newEmail)
+	if isUniqueViolation(err) {
+		return "", "", models.ErrEmailTaken
+	}
+	if err != nil {
+		return "", "", err
+	}
+	if err = checkAffected(res); err != nil {
+		return "", "", err
+	}
+
+	for _, query := range []string{
+		"UPDATE tasks SET user_email = $2 WHERE user_email = $1",
+		"UPDATE tasks SET deleted_by = $2 WHERE deleted_by = $1",
+		"UPDATE tasks SET remind_email = $2 WHERE remind_email = $1",
+		"UPDATE task_events SET user_email = $2 WHERE user_email = $1",
+	} {
+		_, err = tx.ExecContext(ctx, query, oldEmail, newEmail)
+		if err != nil {
+			return "", "", err
+		}
+	}
+
+	// Регистрация на новую почту теперь не сможет завершиться
+	_, err = tx.ExecContext(ctx, "DELETE FROM unverified_users WHERE user_email = $1", newEmail)
+	if err != nil {
+		return "", "", err
+	}
+
+	// Токены сессий выданы на старую почту
+	_, err = tx.ExecContext(ctx, "DELETE FROM sessions WHERE user_email = $1", newEmail)
+	if err != nil {
+		return "", "", err
+	}
+
+	return oldEmail, newEmail, tx.Commit()
+}
@@ -0,0 +1,265 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/artemwebber1/friendly_reminder/internal/models"
+)
+
+type ListsRepository struct {
+	mu sync.Mutex
+	db *sql.DB
+}
+
+func NewListsRepository(db *sql.DB) *ListsRepository {
+	return &ListsRepository{
+		db: db,
+		mu: sync.Mutex{},
+	}
+}
+
+// AddList создаёт новый список пользователя list.UserEmail. Возвращает id созданного списка.
+func (r *ListsRepository) AddList(ctx context.Context, list models.List) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	row := r.db.QueryRowContext(
+		ctx,
+		"INSERT INTO lists(user_email, name, digest) VALUES($1, $2, $3) RETURNING list_id",
+		list.UserEmail, list.Name, list.Digest)
+
+	var id int64
+	err := row.Scan(&id)
+	if err != nil {
+		return -1, err
+	}
+
+	return id, nil
+}
+
+// GetLists возвращает все списки пользователя с указанным email и общие списки, в которые он вступил.
+func (r *ListsRepository) GetLists(ctx context.Context, userEmail string) ([]models.List, error) {
+	rows, err := r.db.QueryContext(
+		ctx,
+		`SELECT list_id, user_email, name, digest, $2::text FROM lists WHERE user_email = $1
+		UNION ALL
+		SELECT lists.list_id, lists.user_email, lists.name, list_members.digest, list_members.role
+		FROM lists JOIN list_members ON list_members.list_id = lists.list_id
+		WHERE list_members.user_email = $1 AND list_members.accepted
+		ORDER BY list_id`,
+		userEmail, models.RoleOwner)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	lists := make([]models.List, 0)
+	for rows.Next() {
+		var l models.List
+		err = rows.Scan(&l.Id, &l.UserEmail, &l.Name, &l.Digest, &l.Role)
+		if err != nil {
+			return nil, err
+		}
+		lists = append(lists, l)
+	}
+
+	return lists, rows.Err()
+}
+
+// UpdateList частично изменяет список с указанным id, принадлежащий пользователю userEmail, и возвращает его новое состояние.
+// Участник общего списка может только включить или выключить рассылку задач из него для себя (patch.Digest).
+// Если такого списка у пользователя нет, возвращает sql.ErrNoRows.
+func (r *ListsRepository) UpdateList(ctx context.Context, id int64, userEmail string, patch models.ListPatch) (models.List, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if patch.Name == nil && patch.Digest != nil {
+		row := r.db.QueryRowContext(
+			ctx,
+			`UPDATE list_members SET digest = $3
+			FROM lists
+			WHERE list_members.list_id = $1 AND list_members.user_email = $2 AND list_members.accepted AND lists.list_id = list_members.list_id
+			RETURNING lists.list_id, lists.user_email, lists.name, list_members.digest, list_members.role`,
+			id, userEmail, *patch.Digest)
+
+		var l models.List
+		err := row.Scan(&l.Id, &l.UserEmail, &l.Name, &l.Digest, &l.Role)
+		if !errors.Is(err, sql.ErrNoRows) {
+			return l, err
+		}
+	}
+
+	sets := make([]string, 0, 2)
+	args := []any{id, userEmail}
+
+	if patch.Name != nil {
+		args = append(args, *patch.Name)
+		sets = append(sets, fmt.Sprintf("name = $%d", len(args)))
+	}
+	if patch.Digest != nil {
+		args = append(args, *patch.Digest)
+		sets = append(sets, fmt.Sprintf("digest = $%d", len(args)))
+	}
+
+	if len(sets) == 0 {
+		return models.List{}, errors.New("nothing to update")
+	}
+
+	row := r.db.QueryRowContext(
+		ctx,
+		"UPDATE lists SET "+strings.Join(sets, ", ")+" WHERE list_id = $1 AND user_email = $2 RETURNING list_id, user_email, name, digest",
+		args...)
+
+	l := models.List{Role: models.RoleOwner}
+	err := row.Scan(&l.Id, &l.UserEmail, &l.Name, &l.Digest)
+	if err != nil {
+		return models.List{}, err
+	}
+
+	return l, nil
+}
+
+// DeleteList удаляет список с указанным id, принадлежащий пользователю userEmail. Задачи списка перемещаются в корзину
+// и после восстановления попадают в список по умолчанию. Если такого списка у пользователя нет, возвращает sql.ErrNoRows.
+func (r *ListsRepository) DeleteList(ctx context.Context, id int64, userEmail string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(
+		ctx,
+		logged(`UPDATE tasks SET deleted_at = now(), deleted_by = $2
+		WHERE list_id = $1 AND `+listOwnedBy("$1", "$2")+` AND deleted_at IS NULL
+		RETURNING task_id`, "$2", models.EventDeleted),
+		id, userEmail)
+	if err != nil {
+		return err
+	}
+
+	// Задачи отвязываются от списка внешним ключом (ON DELETE SET NULL)
+	res, err := tx.ExecContext(ctx, "DELETE FROM lists WHERE list_id = $1 AND user_email = $2", id, userEmail)
+	if err != nil {
+		return err
+	}
+
+	if err = checkAffected(res); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// InviteMember приглашает пользователя member.UserEmail в список member.ListId, принадлежащий пользователю ownerEmail,
+// с ролью member.Role и возвращает этот список. Если пользователь уже приглашён, меняет его роль.
+// Если такого списка у владельца нет, возвращает sql.ErrNoRows.
+func (r *ListsRepository) InviteMember(ctx context.Context, ownerEmail string, member models.ListMember) (models.List, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	row := r.db.QueryRowContext(
+		ctx,
+		`WITH list AS (SELECT list_id, user_email, name, digest FROM lists WHERE list_id = $1 AND user_email = $2),
+		invited AS (
+			INSERT INTO list_members(list_id, user_email, role)
+			SELECT list_id, $3::text, $4::text FROM list
+			ON CONFLICT (list_id, user_email) DO UPDATE SET role = EXCLUDED.role
+		)
+		SELECT list_id, user_email, name, digest FROM list`,
+		member.ListId, ownerEmail, member.UserEmail, member.Role)
+
+	l := models.List{Role: models.RoleOwner}
+	err := row.Scan(&l.Id, &l.UserEmail, &l.Name, &l.Digest)
+	if err != nil {
+		return models.List{}, err
+	}
+
+	return l, nil
+}
+
+// AcceptInvite принимает приглашение пользователя userEmail в список с указанным id и возвращает этот список.
+// Если пользователя не приглашали в такой список, возвращает sql.ErrNoRows.
+func (r *ListsRepository) AcceptInvite(ctx context.Context, id int64, userEmail string) (models.List, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	row := r.db.QueryRowContext(
+		ctx,
+		`UPDATE list_members SET accepted = true
+		FROM lists
+		WHERE list_members.list_id = $1 AND list_members.user_email = $2 AND lists.list_id = list_members.list_id
+		RETURNING lists.list_id, lists.user_email, lists.name, list_members.digest, list_members.role`,
+		id, userEmail)
+
+	var l models.List
+	err := row.Scan(&l.Id, &l.UserEmail, &l.Name, &l.Digest, &l.Role)
+	if err != nil {
+		return models.List{}, err
+	}
+
+	return l, nil
+}
+
+// GetMembers возвращает участников списка с указанным id, включая ещё не принявших приглашение.
+// Участников видят владелец списка и пользователи, вступившие в него.
+// Если список недоступен пользователю userEmail, возвращает sql.ErrNoRows.
+func (r *ListsRepository) GetMembers(ctx context.Context, id int64, userEmail string) ([]models.ListMember, error) {
+	var exists bool
+	err := r.db.QueryRowContext(
+		ctx,
+		`SELECT true FROM lists WHERE list_id = $1 AND (user_email = $2
+			OR list_id IN (SELECT list_id FROM list_members WHERE user_email = $2 AND accepted))`,
+		id, userEmail).Scan(&exists)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := r.db.QueryContext(
+		ctx,
+		"SELECT list_id, user_email, role, accepted FROM list_members WHERE list_id = $1 ORDER BY user_email",
+		id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	members := make([]models.ListMember, 0)
+	for rows.Next() {
+		var m models.ListMember
+		err = rows.Scan(&m.ListId, &m.UserEmail, &m.Role, &m.Accepted)
+		if err != nil {
+			return nil, err
+		}
+		members = append(members, m)
+	}
+
+	return members, rows.Err()
+}
+
+// RemoveMember исключает пользователя memberEmail из списка с указанным id.
+// Исключать участников может владелец списка, а выйти из списка - сам участник (memberEmail == userEmail).
+// Задачи списка остаются у владельца. Если такого участника нет или у пользователя userEmail нет прав, возвращает sql.ErrNoRows.
+func (r *ListsRepository) RemoveMember(ctx context.Context, id int64, memberEmail, userEmail string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	res, err := r.db.ExecContext(
+		ctx,
+		`DELETE FROM list_members WHERE list_id = $1 AND user_email = $2
+		AND ($2 = $3 OR list_id IN (SELECT list_id FROM lists WHERE user_email = $3))`,
+		id, memberEmail, userEmail)
+	if err != nil {
+		return err
+	}
+
+	return checkAffected(res)
+}
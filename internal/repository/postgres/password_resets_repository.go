@@ -0,0 +1,86 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+	"time"
+
+	"github.com/artemwebber1/friendly_reminder/pkg/authorization"
+)
+
+type PasswordResetsRepository struct {
+	mu sync.Mutex
+	db *sql.DB
+}
+
+func NewPasswordResetsRepository(db *sql.DB) *PasswordResetsRepository {
+	return &PasswordResetsRepository{
+		db: db,
+		mu: sync.Mutex{},
+	}
+}
+
+// CreateResetToken создаёт токен для сброса пароля пользователя email, действующий до момента expiresAt, и возвращает его.
+// Ранее выданные пользователю токены при этом перестают действовать.
+func (r *PasswordResetsRepository) CreateResetToken(ctx context.Context, email string, expiresAt time.Time) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, "DELETE FROM password_resets WHERE user_email = $1", email)
+	if err != nil {
+		return "", err
+	}
+
+	token := generateToken()
+	_, err = tx.ExecContext(
+		ctx,
+		"INSERT INTO password_resets(token_hash, user_email, expires_at) VALUES($1, $2, $3)",
+		authorization.HashToken(token), email, expiresAt)
+	if err != nil {
+		return "", err
+	}
+
+	return token, tx.Commit()
+}
+
+// ResetPassword заменяет хэш пароля пользователя, которому выдан токен token, на passwordHash
+// и завершает все сессии пользователя. Токен после этого перестаёт действовать.
+// Возвращает почту пользователя. Если токен не существует или истёк, возвращает sql.ErrNoRows.
+func (r *PasswordResetsRepository) ResetPassword(ctx context.Context, token, passwordHash string) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	var email string
+	err = tx.QueryRowContext(
+		ctx,
+		"DELETE FROM password_resets WHERE token_hash = $1 AND expires_at > now() RETURNING user_email",
+		authorization.HashToken(token)).Scan(&email)
+	if err != nil {
+		return "", err
+	}
+
+	_, err = tx.ExecContext(ctx, "UPDATE users SET password = $1 WHERE email = $2", passwordHash, email)
+	if err != nil {
+		return "", err
+	}
+
+	_, err = tx.ExecContext(ctx, "DELETE FROM sessions WHERE user_email = $1", email)
+	if err != nil {
+		return "", err
+	}
+
+	return email, tx.Commit()
+}
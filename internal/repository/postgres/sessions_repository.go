@@ -0,0 +1,111 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sync"
+	"time"
+)
+
+type SessionsRepository struct {
+	mu sync.Mutex
+	db *sql.DB
+}
+
+func NewSessionsRepository(db *sql.DB) *SessionsRepository {
+	return &SessionsRepository{
+		db: db,
+		mu: sync.Mutex{},
+	}
+}
+
+// CreateSession создаёт сессию пользователя email с refresh токеном, хэш которого равен refreshHash,
+// действующим до момента expiresAt. Возвращает id сессии. Истёкшие сессии пользователя при этом удаляются.
+func (r *SessionsRepository) CreateSession(ctx context.Context, email, refreshHash string, expiresAt time.Time) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	_, err := r.db.ExecContext(ctx, "DELETE FROM sessions WHERE user_email = $1 AND expires_at <= now()", email)
+	if err != nil {
+		return -1, err
+	}
+
+	row := r.db.QueryRowContext(
+		ctx,
+		"INSERT INTO sessions(user_email, refresh_hash, expires_at) VALUES($1, $2, $3) RETURNING session_id",
+		email, refreshHash, expiresAt)
+
+	var id int64
+	err = row.Scan(&id)
+	if err != nil {
+		return -1, err
+	}
+
+	return id, nil
+}
+
+// RotateRefreshToken заменяет действующий refresh токен сессии с хэшем oldHash на токен с хэшем newHash,
+// действующий до момента expiresAt. Возвращает id сессии и почту её пользователя.
+// Старый токен после этого недействителен. Если действующего токена с хэшем oldHash нет, возвращает sql.ErrNoRows.
+//
+// Если oldHash - хэш уже заменённого токена сессии, токен использован повторно (например, его украли),
+// поэтому сессия завершается, и её текущий токен тоже перестаёт действовать.
+func (r *SessionsRepository) RotateRefreshToken(ctx context.Context, oldHash, newHash string, expiresAt time.Time) (int64, string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	row := r.db.QueryRowContext(
+		ctx,
+		`UPDATE sessions SET previous_hash = refresh_hash, refresh_hash = $2, expires_at = $3
+		WHERE refresh_hash = $1 AND expires_at > now()
+		RETURNING session_id, user_email`,
+		oldHash, newHash, expiresAt)
+
+	var (
+		id    int64
+		email string
+	)
+	err := row.Scan(&id, &email)
+	if errors.Is(err, sql.ErrNoRows) {
+		_, delErr := r.db.ExecContext(ctx, "DELETE FROM sessions WHERE previous_hash = $1", oldHash)
+		if delErr != nil {
+			return -1, "", delErr
+		}
+	}
+	if err != nil {
+		return -1, "", err
+	}
+
+	return id, email, nil
+}
+
+// DeleteSession завершает сессию пользователя email с указанным id.
+// Если такой сессии у пользователя нет, возвращает sql.ErrNoRows.
+func (r *SessionsRepository) DeleteSession(ctx context.Context, id int64, email string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	res, err := r.db.ExecContext(ctx, "DELETE FROM sessions WHERE session_id = $1 AND user_email = $2", id, email)
+	if err != nil {
+		return err
+	}
+
+	return checkAffected(res)
+}
+
+// DeleteOtherSessions завершает все сессии пользователя email, кроме сессии с id keep.
+func (r *SessionsRepository) DeleteOtherSessions(ctx context.Context, email string, keep int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	_, err := r.db.ExecContext(ctx, "DELETE FROM sessions WHERE user_email = $1 AND session_id <> $2", email, keep)
+	return err
+}
+
+// SessionActive возвращает true, если сессия с указанным id существует и не истекла.
+func (r *SessionsRepository) SessionActive(ctx context.Context, id int64) bool {
+	var active bool
+	err := r.db.QueryRowContext(ctx, "SELECT true FROM sessions WHERE session_id = $1 AND expires_at > now()", id).Scan(&active)
+	return err == nil
+}
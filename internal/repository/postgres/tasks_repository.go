@@ -3,11 +3,35 @@ package sqlite
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/artemwebber1/friendly_reminder/internal/models"
+	"github.com/artemwebber1/friendly_reminder/pkg/rrule"
+	"github.com/lib/pq"
 )
 
+// taskColumns - столбцы таблицы tasks в том порядке, в котором их сканирует scanTasks.
+// Последние столбцы - метки задачи из таблицы task_tags и пункты её чек-листа из таблицы task_items в виде JSON массива.
+const taskColumns = "task_id, user_email, list_id, value, due_date, remind_at, " +
+	"CASE WHEN remind_at IS NULL THEN '' WHEN " + remindReadable + " THEN remind_email ELSE user_email END, " +
+	"completed, completed_at, priority, position, created_at, recurrence, notes, deleted_at, " +
+	"ARRAY(SELECT tag FROM task_tags WHERE task_tags.task_id = tasks.task_id ORDER BY tag), " +
+	"(SELECT COALESCE(json_agg(json_build_object(" +
+	"'item_id', item_id, 'task_id', task_id, 'value', value, 'done', done, 'position', position) ORDER BY position, item_id), '[]') " +
+	"FROM task_items WHERE task_items.task_id = tasks.task_id)"
+
+// remindReadable - SQL условие, которое истинно, если пользователь, установивший напоминание о задаче, всё ещё имеет к ней доступ.
+const remindReadable = "(tasks.remind_email = tasks.user_email OR tasks.list_id IN " +
+	"(SELECT list_id FROM list_members WHERE list_members.user_email = tasks.remind_email AND accepted))"
+
+// itemColumns - столбцы таблицы task_items в том порядке, в котором их сканирует scanItem.
+const itemColumns = "item_id, task_id, value, done, position"
+
 type TasksRepository struct {
 	mu sync.Mutex
 	db *sql.DB
@@ -20,53 +44,1219 @@ func NewTasksRepository(db *sql.DB) *TasksRepository {
 	}
 }
 
-// AddItem добавляет новую задачу в список пользователя. Возвращает id созданной задачи.
-func (r *TasksRepository) AddTask(ctx context.Context, value, userEmail string) (int64, error) {
+// AddTask добавляет новую задачу от имени пользователя task.UserEmail и возвращает её в том виде, в котором она сохранена.
+// Задача из общего списка принадлежит владельцу списка.
+// Если указан task.ListId, а такого списка у пользователя нет или он в нём не редактор, возвращает sql.ErrNoRows.
+func (r *TasksRepository) AddTask(ctx context.Context, task models.Task) (models.Task, error) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
-	row := r.db.QueryRowContext(ctx, "INSERT INTO tasks(value, user_email) VALUES($1, $2) RETURNING task_id", value, userEmail)
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return models.Task{}, err
+	}
+	defer tx.Rollback()
 
-	var id int64
-	err := row.Scan(&id)
+	id, err := insertTask(ctx, tx, task)
 	if err != nil {
-		return -1, err
+		return models.Task{}, err
 	}
 
-	return id, nil
+	created, err := getTask(ctx, tx, id)
+	if err != nil {
+		return models.Task{}, err
+	}
+
+	return created, tx.Commit()
 }
 
-// DeleteTask удаляет задачу по указанному id.
-func (r *TasksRepository) DeleteTask(ctx context.Context, id int64) error {
+// DeleteTask перемещает в корзину задачу с указанным id, принадлежащую пользователю userEmail.
+// Если такой задачи у пользователя нет, возвращает sql.ErrNoRows.
+func (r *TasksRepository) DeleteTask(ctx context.Context, id int64, userEmail string) error {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
-	_, err := r.db.ExecContext(ctx, "DELETE FROM tasks WHERE task_id = $1", id)
-	return err
+	return deleteTask(ctx, r.db, id, userEmail)
 }
 
 // GetList возвращает список дел пользователя с указанным email.
-func (r *TasksRepository) GetList(ctx context.Context, userEmail string) ([]models.Task, error) {
-	rows, err := r.db.QueryContext(ctx, "SELECT task_id, user_email, value FROM tasks WHERE user_email = $1", userEmail)
+// Параметр opts позволяет отфильтровать задачи по сроку выполнения и отсортировать их.
+func (r *TasksRepository) GetList(ctx context.Context, userEmail string, opts models.ListOptions) ([]models.Task, error) {
+	query := strings.Builder{}
+	query.WriteString("SELECT " + taskColumns + " FROM tasks WHERE " + taskReadableBy("$1") + " AND deleted_at IS NULL")
+	args := []any{userEmail}
+
+	switch {
+	case opts.Completed != nil:
+		args = append(args, *opts.Completed)
+		fmt.Fprintf(&query, " AND completed = $%d", len(args))
+	case !opts.IncludeCompleted:
+		query.WriteString(" AND completed = false")
+	}
+
+	if opts.ListId != nil {
+		args = append(args, *opts.ListId)
+		fmt.Fprintf(&query, " AND list_id = $%d", len(args))
+	}
+
+	if opts.Tag != "" {
+		args = append(args, opts.Tag)
+		fmt.Fprintf(&query, " AND EXISTS (SELECT 1 FROM task_tags WHERE task_tags.task_id = tasks.task_id AND tag = $%d)", len(args))
+	}
+
+	if opts.DigestOnly {
+		// Рассылку общего списка каждый участник включает для себя, владелец - в самом списке
+		query.WriteString(" AND (list_id IS NULL" +
+			" OR list_id IN (SELECT list_id FROM lists WHERE lists.user_email = $1 AND digest)" +
+			" OR list_id IN (SELECT list_id FROM list_members WHERE list_members.user_email = $1 AND accepted AND digest))")
+	}
+
+	bound := func(cond string, t *time.Time) {
+		if t != nil {
+			args = append(args, *t)
+			fmt.Fprintf(&query, " AND "+cond, len(args))
+		}
+	}
+
+	now := time.Now()
+	if opts.Location != nil {
+		now = now.In(opts.Location)
+	}
+
+	from, to := dueBounds(opts.Due, now)
+	bound("due_date >= $%d", from)
+	bound("due_date < $%d", to)
+	bound("due_date >= $%d", opts.DueFrom)
+	bound("due_date < $%d", opts.DueTo)
+	bound("created_at >= $%d", opts.CreatedFrom)
+	bound("created_at < $%d", opts.CreatedTo)
+
+	if opts.After != nil {
+		fmt.Fprintf(&query, " AND %s", afterCursor(*opts.After, &args))
+	}
+
+	switch opts.Sort {
+	case models.SortDue:
+		query.WriteString(" ORDER BY due_date ASC NULLS LAST, priority DESC, created_at, task_id")
+	case models.SortPosition:
+		query.WriteString(" ORDER BY position, task_id")
+	case models.SortCreated:
+		query.WriteString(" ORDER BY created_at DESC, task_id DESC")
+	default:
+		query.WriteString(" ORDER BY priority DESC, created_at, task_id")
+	}
+
+	if opts.Limit > 0 {
+		args = append(args, opts.Limit)
+		fmt.Fprintf(&query, " LIMIT $%d", len(args))
+	}
+
+	rows, err := r.db.QueryContext(ctx, query.String(), args...)
 	if err != nil {
 		return []models.Task{}, err
 	}
+	defer rows.Close()
 
-	tasks := make([]models.Task, 0)
-	var task models.Task
-	for rows.Next() {
-		rows.Scan(&task.Id, &task.UserEmail, &task.Value)
-		tasks = append(tasks, task)
+	return scanTasks(rows)
+}
+
+// Search ищет задачи пользователя userEmail по тексту и описанию с помощью полнотекстового поиска Postgres.
+// Запрос разбирается одновременно по правилам русского и английского языков.
+// Задачи в корзине не ищутся.
+func (r *TasksRepository) Search(ctx context.Context, userEmail string, q models.SearchQuery) (models.SearchResult, error) {
+	const tsQuery = "(websearch_to_tsquery('russian', $2) || websearch_to_tsquery('english', $2))"
+
+	where := taskReadableBy("$1") + " AND deleted_at IS NULL AND search_vector @@ " + tsQuery
+	if !q.IncludeCompleted {
+		where += " AND completed = false"
 	}
 
-	return tasks, nil
+	res := models.SearchResult{}
+	err := r.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM tasks WHERE "+where, userEmail, q.Text).Scan(&res.Total)
+	if err != nil {
+		return res, err
+	}
+
+	rows, err := r.db.QueryContext(
+		ctx,
+		"SELECT "+taskColumns+" FROM tasks WHERE "+where+
+			" ORDER BY ts_rank(search_vector, "+tsQuery+") DESC, created_at DESC, task_id LIMIT $3 OFFSET $4",
+		userEmail, q.Text, q.Limit, q.Offset)
+	if err != nil {
+		return res, err
+	}
+	defer rows.Close()
+
+	res.Tasks, err = scanTasks(rows)
+	return res, err
 }
 
-// ClearList очищает список указанного пользователя.
+// GetDueReminders возвращает задачи всех пользователей, время напоминания о которых уже наступило к моменту now,
+// но напоминание ещё не было отправлено.
+func (r *TasksRepository) GetDueReminders(ctx context.Context, now time.Time) ([]models.Task, error) {
+	rows, err := r.db.QueryContext(
+		ctx,
+		"SELECT "+taskColumns+" FROM tasks WHERE remind_at <= $1 AND reminder_sent = false AND completed = false AND deleted_at IS NULL ORDER BY remind_at",
+		now)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanTasks(rows)
+}
+
+// MarkReminderSent помечает напоминание о задаче с указанным id как отправленное.
+func (r *TasksRepository) MarkReminderSent(ctx context.Context, id int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	_, err := r.db.ExecContext(ctx, "UPDATE tasks SET reminder_sent = true WHERE task_id = $1", id)
+	return err
+}
+
+// UpdateTask частично изменяет задачу с указанным id, принадлежащую пользователю userEmail, и возвращает её новое состояние.
+// Если такой задачи у пользователя нет, возвращает sql.ErrNoRows.
+// При изменении времени напоминания напоминание будет отправлено заново.
+func (r *TasksRepository) UpdateTask(ctx context.Context, id int64, userEmail string, patch models.TaskPatch) (models.Task, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	sets := make([]string, 0, 4)
+	args := []any{id, userEmail}
+	changes := make(map[string]any) // Изменённые поля для истории задачи
+
+	set := func(column string, value any) {
+		args = append(args, value)
+		sets = append(sets, fmt.Sprintf("%s = $%d", column, len(args)))
+		changes[column] = value
+	}
+
+	if patch.Value != nil {
+		set("value", *patch.Value)
+	}
+	if patch.Notes != nil {
+		set("notes", *patch.Notes)
+	}
+	if patch.DueDate.Set {
+		set("due_date", patch.DueDate.Value)
+	}
+	if patch.RemindAt.Set {
+		set("remind_at", patch.RemindAt.Value)
+		// Напоминание придёт тому, кто его изменил
+		sets = append(sets, "reminder_sent = false", "remind_email = $2")
+	}
+
+	if patch.Priority != nil {
+		set("priority", *patch.Priority)
+	}
+	if patch.Recurrence != nil {
+		set("recurrence", *patch.Recurrence)
+	}
+
+	where := "task_id = $1 AND " + taskWritableBy("$2") + " AND deleted_at IS NULL"
+	if patch.ListId.Set {
+		// Переносить задачу между списками может только её владелец
+		set("list_id", patch.ListId.Value)
+		where += " AND user_email = $2 AND " + listOwnedBy(fmt.Sprintf("$%d", len(args)), "$2")
+	}
+
+	if len(sets) == 0 {
+		return models.Task{}, errors.New("nothing to update")
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return models.Task{}, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(
+		ctx,
+		"UPDATE tasks SET "+strings.Join(sets, ", ")+" WHERE "+where+" RETURNING "+taskColumns,
+		args...)
+	if err != nil {
+		return models.Task{}, err
+	}
+
+	tasks, err := scanTasks(rows)
+	rows.Close()
+	if err != nil {
+		return models.Task{}, err
+	}
+
+	if len(tasks) == 0 {
+		return models.Task{}, sql.ErrNoRows
+	}
+
+	err = logEvent(ctx, tx, id, userEmail, models.EventEdited, changes)
+	if err != nil {
+		return models.Task{}, err
+	}
+
+	return tasks[0], tx.Commit()
+}
+
+// SetCompleted отмечает задачу с указанным id, принадлежащую пользователю userEmail, как выполненную (completed = true)
+// или снова невыполненную (completed = false). Если такой задачи у пользователя нет, возвращает sql.ErrNoRows.
+//
+// Если выполнена повторяющаяся задача, создаётся её следующее повторение, которое и возвращается.
+// Повторение создаётся один раз: если задачу снова открыть и выполнить, возвращается уже созданное повторение.
+// В остальных случаях возвращается nil.
+func (r *TasksRepository) SetCompleted(ctx context.Context, id int64, userEmail string, completed bool) (*models.Task, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	next, err := setCompleted(ctx, tx, id, userEmail, completed)
+	if err != nil {
+		return nil, err
+	}
+
+	return next, tx.Commit()
+}
+
+// Batch выполняет операции ops над задачами пользователя userEmail в одной транзакции и возвращает результат каждой из них.
+//
+// Если atomic = true, при первой же ошибке выполнение прекращается и все изменения отменяются; результаты операций,
+// которые так и не были выполнены, остаются пустыми. Иначе отменяются только изменения неудавшихся операций.
+// Второе возвращаемое значение равно true, если изменения сохранены.
+//
+// Ошибки отдельных операций (например, sql.ErrNoRows для чужой задачи) возвращаются в их результатах.
+func (r *TasksRepository) Batch(ctx context.Context, userEmail string, ops []models.BatchOperation, atomic bool) ([]models.BatchResult, bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, false, err
+	}
+	defer tx.Rollback()
+
+	results := make([]models.BatchResult, len(ops))
+	for i, op := range ops {
+		// Ошибка в Postgres прерывает всю транзакцию, поэтому каждая операция выполняется после точки сохранения,
+		// к которой можно откатиться
+		if !atomic {
+			if _, err = tx.ExecContext(ctx, "SAVEPOINT batch_op"); err != nil {
+				return nil, false, err
+			}
+		}
+
+		results[i].Task, results[i].Err = batchOperation(ctx, tx, userEmail, op)
+
+		switch {
+		case results[i].Err != nil && atomic:
+			return results, false, nil
+		case results[i].Err != nil:
+			_, err = tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT batch_op")
+		case !atomic:
+			_, err = tx.ExecContext(ctx, "RELEASE SAVEPOINT batch_op")
+		}
+		if err != nil {
+			return nil, false, err
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, false, err
+	}
+	return results, true, nil
+}
+
+// MoveTask переставляет задачу с указанным id, которую пользователь userEmail может изменять, непосредственно перед задачей anchorId
+// (или сразу после неё, если after = true). Задачи переставляются внутри одного списка: обе задачи должны быть в одном списке
+// и не в корзине, иначе возвращается sql.ErrNoRows.
+func (r *TasksRepository) MoveTask(ctx context.Context, id int64, userEmail string, anchorId int64, after bool) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Задачи общего списка принадлежат его владельцу, поэтому список задачи определяется парой (владелец, list_id)
+	var owner string
+	var listId *int64
+	err = tx.QueryRowContext(
+		ctx,
+		"SELECT user_email, list_id FROM tasks WHERE task_id = $1 AND "+taskWritableBy("$2")+" AND deleted_at IS NULL",
+		id, userEmail).Scan(&owner, &listId)
+	if err != nil {
+		return err
+	}
+
+	// inList - условие на задачи того же списка, не находящиеся в корзине
+	const inList = "user_email = $1 AND list_id IS NOT DISTINCT FROM $2::bigint AND deleted_at IS NULL"
+
+	// Блокируем задачи списка, чтобы параллельные перестановки не перемешали позиции
+	_, err = tx.ExecContext(ctx, "SELECT task_id FROM tasks WHERE "+inList+" FOR UPDATE", owner, listId)
+	if err != nil {
+		return err
+	}
+
+	var taskPos, anchorPos int64
+	err = tx.QueryRowContext(ctx, "SELECT position FROM tasks WHERE "+inList+" AND task_id = $3", owner, listId, id).Scan(&taskPos)
+	if err != nil {
+		return err
+	}
+
+	err = tx.QueryRowContext(ctx, "SELECT position FROM tasks WHERE "+inList+" AND task_id = $3", owner, listId, anchorId).Scan(&anchorPos)
+	if err != nil {
+		return err
+	}
+
+	if id == anchorId {
+		return tx.Commit()
+	}
+
+	// Убираем задачу с её места, сдвигая следующие за ней задачи на одну позицию вверх
+	_, err = tx.ExecContext(
+		ctx,
+		"UPDATE tasks SET position = position - 1 WHERE "+inList+" AND position > $3",
+		owner, listId, taskPos)
+	if err != nil {
+		return err
+	}
+
+	if anchorPos > taskPos {
+		anchorPos--
+	}
+
+	newPos := anchorPos
+	if after {
+		newPos++
+	}
+
+	// Освобождаем место для задачи
+	_, err = tx.ExecContext(
+		ctx,
+		"UPDATE tasks SET position = position + 1 WHERE "+inList+" AND position >= $3 AND task_id <> $4",
+		owner, listId, newPos, id)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, "UPDATE tasks SET position = $1 WHERE task_id = $2", newPos, id)
+	if err != nil {
+		return err
+	}
+
+	err = logEvent(ctx, tx, id, userEmail, models.EventEdited, map[string]any{"position": newPos})
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// AddTags добавляет метки задаче с указанным id, принадлежащей пользователю userEmail. Уже существующие метки пропускаются.
+// Если такой задачи у пользователя нет, возвращает sql.ErrNoRows.
+func (r *TasksRepository) AddTags(ctx context.Context, id int64, userEmail string, tags []string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = checkTaskAccess(ctx, tx, id, userEmail, true)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(
+		ctx,
+		"INSERT INTO task_tags(task_id, tag) SELECT $1::bigint, unnest($2::text[]) ON CONFLICT DO NOTHING",
+		id, pq.Array(tags))
+	if err != nil {
+		return err
+	}
+
+	err = logEvent(ctx, tx, id, userEmail, models.EventEdited, map[string]any{"tags_added": tags})
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// RemoveTag удаляет метку у задачи с указанным id, принадлежащей пользователю userEmail.
+// Если такой задачи у пользователя нет, возвращает sql.ErrNoRows.
+func (r *TasksRepository) RemoveTag(ctx context.Context, id int64, userEmail, tag string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = checkTaskAccess(ctx, tx, id, userEmail, true)
+	if err != nil {
+		return err
+	}
+
+	res, err := tx.ExecContext(ctx, "DELETE FROM task_tags WHERE task_id = $1 AND tag = $2", id, tag)
+	if err != nil {
+		return err
+	}
+
+	if checkAffected(res) == nil {
+		err = logEvent(ctx, tx, id, userEmail, models.EventEdited, map[string]any{"tags_removed": []string{tag}})
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// GetItems возвращает пункты чек-листа задачи с указанным id, принадлежащей пользователю userEmail.
+// Если такой задачи у пользователя нет, возвращает sql.ErrNoRows.
+func (r *TasksRepository) GetItems(ctx context.Context, taskId int64, userEmail string) ([]models.TaskItem, error) {
+	err := checkTaskAccess(ctx, r.db, taskId, userEmail, false)
+	if err != nil {
+		return nil, err
+	}
+
+	return getItems(ctx, r.db, taskId)
+}
+
+// AddItem добавляет пункт в конец чек-листа задачи с указанным id, принадлежащей пользователю userEmail, и возвращает его.
+// Если такой задачи у пользователя нет, возвращает sql.ErrNoRows.
+func (r *TasksRepository) AddItem(ctx context.Context, taskId int64, userEmail, value string) (models.TaskItem, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return models.TaskItem{}, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(
+		ctx,
+		`INSERT INTO task_items(task_id, value, position)
+		SELECT task_id, $3::text, COALESCE((SELECT MAX(position) FROM task_items WHERE task_id = $1), 0) + 1
+		FROM tasks WHERE task_id = $1 AND `+taskWritableBy("$2")+` AND deleted_at IS NULL
+		RETURNING `+itemColumns,
+		taskId, userEmail, value)
+	if err != nil {
+		return models.TaskItem{}, err
+	}
+
+	item, err := scanItem(rows)
+	rows.Close()
+	if err != nil {
+		return models.TaskItem{}, err
+	}
+
+	err = logEvent(ctx, tx, taskId, userEmail, models.EventEdited, map[string]any{"item_added": item})
+	if err != nil {
+		return models.TaskItem{}, err
+	}
+
+	return item, tx.Commit()
+}
+
+// UpdateItem частично изменяет пункт itemId чек-листа задачи taskId, принадлежащей пользователю userEmail, и возвращает его новое состояние.
+// Если такого пункта у задачи пользователя нет, возвращает sql.ErrNoRows.
+func (r *TasksRepository) UpdateItem(ctx context.Context, taskId, itemId int64, userEmail string, patch models.TaskItemPatch) (models.TaskItem, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	sets := make([]string, 0, 2)
+	args := []any{itemId, taskId, userEmail}
+	changes := map[string]any{"item_id": itemId}
+
+	if patch.Value != nil {
+		args = append(args, *patch.Value)
+		sets = append(sets, fmt.Sprintf("value = $%d", len(args)))
+		changes["value"] = *patch.Value
+	}
+	if patch.Done != nil {
+		args = append(args, *patch.Done)
+		sets = append(sets, fmt.Sprintf("done = $%d", len(args)))
+		changes["done"] = *patch.Done
+	}
+
+	if len(sets) == 0 {
+		return models.TaskItem{}, errors.New("nothing to update")
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return models.TaskItem{}, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(
+		ctx,
+		"UPDATE task_items SET "+strings.Join(sets, ", ")+
+			" WHERE item_id = $1 AND task_id = $2 AND task_id IN (SELECT task_id FROM tasks WHERE "+taskWritableBy("$3")+" AND deleted_at IS NULL)"+
+			" RETURNING "+itemColumns,
+		args...)
+	if err != nil {
+		return models.TaskItem{}, err
+	}
+
+	item, err := scanItem(rows)
+	rows.Close()
+	if err != nil {
+		return models.TaskItem{}, err
+	}
+
+	err = logEvent(ctx, tx, taskId, userEmail, models.EventEdited, map[string]any{"item_updated": changes})
+	if err != nil {
+		return models.TaskItem{}, err
+	}
+
+	return item, tx.Commit()
+}
+
+// DeleteItem удаляет пункт itemId из чек-листа задачи taskId, принадлежащей пользователю userEmail.
+// Если такого пункта у задачи пользователя нет, возвращает sql.ErrNoRows.
+func (r *TasksRepository) DeleteItem(ctx context.Context, taskId, itemId int64, userEmail string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var value string
+	err = tx.QueryRowContext(
+		ctx,
+		"DELETE FROM task_items WHERE item_id = $1 AND task_id = $2 AND task_id IN (SELECT task_id FROM tasks WHERE "+taskWritableBy("$3")+" AND deleted_at IS NULL) RETURNING value",
+		itemId, taskId, userEmail).Scan(&value)
+	if err != nil {
+		return err
+	}
+
+	err = logEvent(ctx, tx, taskId, userEmail, models.EventEdited, map[string]any{"item_removed": map[string]any{"item_id": itemId, "value": value}})
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// ClearList перемещает в корзину все задачи указанного пользователя.
 func (r *TasksRepository) ClearList(ctx context.Context, userEmail string) error {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
-	_, err := r.db.ExecContext(ctx, "DELETE FROM tasks WHERE user_email = $1", userEmail)
+	_, err := r.db.ExecContext(
+		ctx,
+		logged("UPDATE tasks SET deleted_at = now(), deleted_by = $1 WHERE user_email = $1 AND deleted_at IS NULL RETURNING task_id", "$1", models.EventDeleted),
+		userEmail)
+	return err
+}
+
+// GetHistory возвращает историю изменений задачи с указанным id, принадлежащей пользователю userEmail, в хронологическом порядке.
+// История доступна и для задач в корзине, но удаляется вместе с задачей, когда та удаляется из корзины окончательно.
+// Если такой задачи у пользователя нет, возвращает sql.ErrNoRows.
+func (r *TasksRepository) GetHistory(ctx context.Context, id int64, userEmail string) ([]models.TaskEvent, error) {
+	var exists bool
+	err := r.db.QueryRowContext(ctx, "SELECT true FROM tasks WHERE task_id = $1 AND "+taskReadableBy("$2"), id, userEmail).Scan(&exists)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := r.db.QueryContext(
+		ctx,
+		"SELECT event_id, task_id, user_email, event, details, created_at FROM task_events WHERE task_id = $1 ORDER BY event_id",
+		id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := make([]models.TaskEvent, 0)
+	for rows.Next() {
+		var e models.TaskEvent
+		var details []byte
+		err = rows.Scan(&e.Id, &e.TaskId, &e.UserEmail, &e.Type, &details, &e.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		e.Details = details
+		events = append(events, e)
+	}
+
+	return events, rows.Err()
+}
+
+// GetTrash возвращает находящиеся в корзине задачи, которые пользователь userEmail может изменять: его собственные задачи
+// и задачи из общих списков, где он редактор, кем бы они ни были удалены. Недавно удалённые задачи идут первыми.
+func (r *TasksRepository) GetTrash(ctx context.Context, userEmail string) ([]models.Task, error) {
+	rows, err := r.db.QueryContext(
+		ctx,
+		"SELECT "+taskColumns+" FROM tasks WHERE "+taskWritableBy("$1")+" AND deleted_at IS NOT NULL ORDER BY deleted_at DESC, position",
+		userEmail)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanTasks(rows)
+}
+
+// RestoreTask возвращает из корзины задачу с указанным id, которую пользователь userEmail может изменять (см. GetTrash).
+// Задача ставится в конец списка её владельца. Если такой задачи в корзине пользователя нет, возвращает sql.ErrNoRows.
+func (r *TasksRepository) RestoreTask(ctx context.Context, id int64, userEmail string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	res, err := r.db.ExecContext(
+		ctx,
+		logged(`UPDATE tasks SET deleted_at = NULL, deleted_by = NULL,
+			position = COALESCE((SELECT MAX(position) FROM tasks t WHERE t.user_email = tasks.user_email AND t.deleted_at IS NULL), 0) + 1
+		WHERE task_id = $1 AND `+taskWritableBy("$2")+` AND deleted_at IS NOT NULL
+		RETURNING task_id`, "$2", models.EventRestored),
+		id, userEmail)
+	if err != nil {
+		return err
+	}
+
+	return checkAffected(res)
+}
+
+// UndoDelete отменяет последнее удаление, сделанное пользователем userEmail: возвращает из корзины задачу, удалённую последней,
+// или все задачи, если последним был очищен весь список или удалён список. Возвращает количество восстановленных задач.
+// Задачи, которые удалили другие участники общих списков, не восстанавливаются.
+//
+// Восстановленные задачи ставятся подряд в конец списков их владельцев в том порядке, в котором они были до удаления.
+func (r *TasksRepository) UndoDelete(ctx context.Context, userEmail string) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// Задачи, удалённые одним запросом, имеют одинаковое время удаления, так как now() в Postgres - время начала транзакции
+	res, err := r.db.ExecContext(
+		ctx,
+		logged(`UPDATE tasks SET deleted_at = NULL, deleted_by = NULL,
+			position = COALESCE((SELECT MAX(position) FROM tasks t WHERE t.user_email = tasks.user_email AND t.deleted_at IS NULL), 0) + restored.n
+		FROM (
+			SELECT task_id, ROW_NUMBER() OVER (PARTITION BY user_email ORDER BY position, task_id) AS n
+			FROM tasks
+			WHERE deleted_by = $1 AND deleted_at = (SELECT MAX(deleted_at) FROM tasks WHERE deleted_by = $1)
+		) restored
+		WHERE tasks.task_id = restored.task_id AND `+taskWritableBy("$1")+`
+		RETURNING tasks.task_id`, "$1", models.EventRestored),
+		userEmail)
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
+
+// PurgeTrash окончательно удаляет задачи всех пользователей, перемещённые в корзину раньше момента before.
+// История удалённых задач удаляется вместе с ними (внешний ключ task_events с ON DELETE CASCADE).
+// Возвращает количество удалённых задач.
+func (r *TasksRepository) PurgeTrash(ctx context.Context, before time.Time) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	res, err := r.db.ExecContext(ctx, "DELETE FROM tasks WHERE deleted_at < $1", before)
+	if err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
+
+// afterCursor возвращает SQL условие, которое истинно для задач, идущих в порядке сортировки c.Sort после курсора c.
+// Значения курсора добавляются в args как параметры запроса.
+func afterCursor(c models.ListCursor, args *[]any) string {
+	arg := func(v any, typ string) string {
+		*args = append(*args, v)
+		return fmt.Sprintf("$%d::%s", len(*args), typ)
+	}
+
+	// Приоритет сортируется по убыванию, поэтому сравнивается со знаком минус
+	switch c.Sort {
+	case models.SortDue:
+		rest := fmt.Sprintf("-%s, %s, %s", arg(c.Priority, "smallint"), arg(c.CreatedAt, "timestamptz"), arg(c.Id, "bigint"))
+		if c.DueDate == nil {
+			// Задачи без срока идут в конце списка
+			return "(due_date IS NULL AND (-priority, created_at, task_id) > (" + rest + "))"
+		}
+		return fmt.Sprintf("(due_date IS NULL OR (due_date, -priority, created_at, task_id) > (%s, %s))", arg(*c.DueDate, "timestamptz"), rest)
+	case models.SortPosition:
+		return fmt.Sprintf("(position, task_id) > (%s, %s)", arg(c.Position, "bigint"), arg(c.Id, "bigint"))
+	case models.SortCreated:
+		return fmt.Sprintf("(created_at, task_id) < (%s, %s)", arg(c.CreatedAt, "timestamptz"), arg(c.Id, "bigint"))
+	default:
+		return fmt.Sprintf("(-priority, created_at, task_id) > (-%s, %s, %s)",
+			arg(c.Priority, "smallint"), arg(c.CreatedAt, "timestamptz"), arg(c.Id, "bigint"))
+	}
+}
+
+// dueBounds возвращает границы полуинтервала [from, to), в который должен попадать срок выполнения задачи
+// для указанного фильтра. Начало дня и недели определяются в часовом поясе now.
+// Если граница не нужна, вместо неё возвращается nil.
+func dueBounds(f models.DueFilter, now time.Time) (from, to *time.Time) {
+	startOfDay := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+
+	switch f {
+	case models.DueOverdue:
+		return nil, &now
+	case models.DueToday:
+		end := startOfDay.AddDate(0, 0, 1)
+		return &startOfDay, &end
+	case models.DueWeek:
+		// Неделя начинается с понедельника
+		offset := (int(startOfDay.Weekday()) + 6) % 7
+		start := startOfDay.AddDate(0, 0, -offset)
+		end := start.AddDate(0, 0, 7)
+		return &start, &end
+	}
+	return nil, nil
+}
+
+// scanTasks считывает задачи из результата запроса, выбирающего столбцы taskColumns.
+func scanTasks(rows *sql.Rows) ([]models.Task, error) {
+	tasks := make([]models.Task, 0)
+	for rows.Next() {
+		var task models.Task
+		var items []byte
+		err := rows.Scan(
+			&task.Id,
+			&task.UserEmail,
+			&task.ListId,
+			&task.Value,
+			&task.DueDate,
+			&task.RemindAt,
+			&task.RemindEmail,
+			&task.Completed,
+			&task.CompletedAt,
+			&task.Priority,
+			&task.Position,
+			&task.CreatedAt,
+			&task.Recurrence,
+			&task.Notes,
+			&task.DeletedAt,
+			pq.Array(&task.Tags),
+			&items)
+		if err != nil {
+			return nil, err
+		}
+
+		if err = json.Unmarshal(items, &task.Items); err != nil {
+			return nil, err
+		}
+		tasks = append(tasks, task)
+	}
+
+	return tasks, rows.Err()
+}
+
+// getItems возвращает пункты чек-листа задачи с указанным id.
+func getItems(ctx context.Context, q querier, taskId int64) ([]models.TaskItem, error) {
+	rows, err := q.QueryContext(
+		ctx,
+		"SELECT "+itemColumns+" FROM task_items WHERE task_id = $1 ORDER BY position, item_id",
+		taskId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := make([]models.TaskItem, 0)
+	for rows.Next() {
+		var item models.TaskItem
+		err = rows.Scan(&item.Id, &item.TaskId, &item.Value, &item.Done, &item.Position)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+
+	return items, rows.Err()
+}
+
+// scanItem считывает единственный пункт чек-листа из результата запроса, выбирающего столбцы itemColumns.
+// Если результат пуст, возвращает sql.ErrNoRows.
+func scanItem(rows *sql.Rows) (models.TaskItem, error) {
+	var item models.TaskItem
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return item, err
+		}
+		return item, sql.ErrNoRows
+	}
+
+	err := rows.Scan(&item.Id, &item.TaskId, &item.Value, &item.Done, &item.Position)
+	return item, err
+}
+
+// batchOperation выполняет одну операцию пакетного запроса и возвращает созданную ею задачу, если она есть.
+func batchOperation(ctx context.Context, q querier, userEmail string, op models.BatchOperation) (*models.Task, error) {
+	switch op.Op {
+	case models.BatchCreate:
+		task := *op.Task
+		task.UserEmail = userEmail
+		task.RemindEmail = ""
+
+		id, err := insertTask(ctx, q, task)
+		if err != nil {
+			return nil, err
+		}
+
+		// Возвращается задача в том виде, в котором она сохранена: метки, позиция и владелец задаются не клиентом
+		created, err := getTask(ctx, q, id)
+		if err != nil {
+			return nil, err
+		}
+		return &created, nil
+	case models.BatchComplete:
+		done := op.Done == nil || *op.Done
+		return setCompleted(ctx, q, op.TaskId, userEmail, done)
+	case models.BatchDelete:
+		return nil, deleteTask(ctx, q, op.TaskId, userEmail)
+	}
+	return nil, fmt.Errorf("unknown operation %q", op.Op)
+}
+
+// getTask возвращает задачу с указанным id без проверки доступа к ней. Если такой задачи нет, возвращает sql.ErrNoRows.
+func getTask(ctx context.Context, q querier, id int64) (models.Task, error) {
+	rows, err := q.QueryContext(ctx, "SELECT "+taskColumns+" FROM tasks WHERE task_id = $1", id)
+	if err != nil {
+		return models.Task{}, err
+	}
+
+	tasks, err := scanTasks(rows)
+	rows.Close()
+	if err != nil {
+		return models.Task{}, err
+	}
+
+	if len(tasks) == 0 {
+		return models.Task{}, sql.ErrNoRows
+	}
+	return tasks[0], nil
+}
+
+// deleteTask перемещает в корзину задачу с указанным id, принадлежащую пользователю userEmail.
+// Если такой задачи у пользователя нет, возвращает sql.ErrNoRows.
+func deleteTask(ctx context.Context, q querier, id int64, userEmail string) error {
+	res, err := q.ExecContext(
+		ctx,
+		logged("UPDATE tasks SET deleted_at = now(), deleted_by = $2 WHERE task_id = $1 AND "+taskWritableBy("$2")+" AND deleted_at IS NULL RETURNING task_id", "$2", models.EventDeleted),
+		id, userEmail)
+	if err != nil {
+		return err
+	}
+
+	return checkAffected(res)
+}
+
+// setCompleted меняет состояние выполнения задачи и создаёт следующее повторение повторяющейся задачи (см. SetCompleted).
+// Должна вызываться внутри транзакции.
+func setCompleted(ctx context.Context, q querier, id int64, userEmail string, completed bool) (*models.Task, error) {
+	rows, err := q.QueryContext(
+		ctx,
+		"SELECT "+taskColumns+" FROM tasks WHERE task_id = $1 AND "+taskWritableBy("$2")+" AND deleted_at IS NULL FOR UPDATE",
+		id, userEmail)
+	if err != nil {
+		return nil, err
+	}
+
+	tasks, err := scanTasks(rows)
+	rows.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(tasks) == 0 {
+		return nil, sql.ErrNoRows
+	}
+	task := tasks[0]
+
+	_, err = q.ExecContext(
+		ctx,
+		"UPDATE tasks SET completed = $1, completed_at = CASE WHEN $1 THEN now() END WHERE task_id = $2",
+		completed, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if completed != task.Completed {
+		event := models.EventCompleted
+		if !completed {
+			event = models.EventReopened
+		}
+
+		if err = logEvent(ctx, q, id, userEmail, event, nil); err != nil {
+			return nil, err
+		}
+	}
+
+	if !completed || task.Completed || task.Recurrence == "" {
+		return nil, nil
+	}
+
+	// Если следующее повторение уже создавалось (задачу выполнили, снова открыли и выполнили ещё раз),
+	// возвращается оно, а новое не создаётся
+	var nextId sql.NullInt64
+	err = q.QueryRowContext(ctx, "SELECT next_task_id FROM tasks WHERE task_id = $1", id).Scan(&nextId)
+	if err != nil {
+		return nil, err
+	}
+
+	if nextId.Valid {
+		rows, err = q.QueryContext(ctx, "SELECT "+taskColumns+" FROM tasks WHERE task_id = $1 AND deleted_at IS NULL", nextId.Int64)
+		if err != nil {
+			return nil, err
+		}
+
+		tasks, err = scanTasks(rows)
+		rows.Close()
+		if err != nil || len(tasks) == 0 {
+			return nil, err
+		}
+		return &tasks[0], nil
+	}
+
+	next, err := nextOccurrence(task, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	if next != nil {
+		// Следующее повторение создаётся от имени того, кто выполнил задачу
+		actor := *next
+		actor.UserEmail = userEmail
+		next.Id, err = insertTask(ctx, q, actor)
+		if err != nil {
+			return nil, err
+		}
+
+		_, err = q.ExecContext(
+			ctx,
+			"INSERT INTO task_tags(task_id, tag) SELECT $1::bigint, tag FROM task_tags WHERE task_id = $2",
+			next.Id, id)
+		if err != nil {
+			return nil, err
+		}
+
+		// Пункты чек-листа переносятся в следующее повторение невыполненными
+		_, err = q.ExecContext(
+			ctx,
+			"INSERT INTO task_items(task_id, value, position) SELECT $1::bigint, value, position FROM task_items WHERE task_id = $2",
+			next.Id, id)
+		if err != nil {
+			return nil, err
+		}
+
+		_, err = q.ExecContext(ctx, "UPDATE tasks SET next_task_id = $1 WHERE task_id = $2", next.Id, id)
+		if err != nil {
+			return nil, err
+		}
+
+		next.Items, err = getItems(ctx, q, next.Id)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return next, nil
+}
+
+// insertTask добавляет задачу от имени пользователя task.UserEmail в конец списка и возвращает её id.
+// Задача из общего списка принадлежит владельцу списка, а task.UserEmail записывается в историю задачи как её автор.
+// Напоминание о задаче придёт пользователю task.RemindEmail, а если он не указан - автору задачи.
+// Должна вызываться внутри транзакции, так как создание задачи записывается в её историю отдельным запросом.
+// Если указан task.ListId, а такого списка у пользователя нет или он не может его изменять, возвращает sql.ErrNoRows.
+func insertTask(ctx context.Context, q querier, task models.Task) (int64, error) {
+	remindEmail := task.RemindEmail
+	if remindEmail == "" {
+		remindEmail = task.UserEmail
+	}
+
+	row := q.QueryRowContext(
+		ctx,
+		`WITH owner AS (SELECT COALESCE((SELECT user_email FROM lists WHERE list_id = $5::bigint), $2::text) AS email)
+		INSERT INTO tasks(value, user_email, due_date, remind_at, remind_email, list_id, priority, recurrence, notes, position)
+		SELECT $1::text, owner.email, $3::timestamptz, $4::timestamptz, CASE WHEN $4::timestamptz IS NULL THEN NULL ELSE $9::text END,
+			$5::bigint, $6::smallint, $7::text, $8::text,
+			COALESCE((SELECT MAX(position) FROM tasks WHERE user_email = owner.email), 0) + 1
+		FROM owner
+		WHERE `+listWritableBy("$5", "$2")+`
+		RETURNING task_id`,
+		task.Value, task.UserEmail, task.DueDate, task.RemindAt, task.ListId, task.Priority, task.Recurrence, task.Notes, remindEmail)
+
+	var id int64
+	err := row.Scan(&id)
+	if err != nil {
+		return -1, err
+	}
+
+	err = logEvent(ctx, q, id, task.UserEmail, models.EventCreated, nil)
+	if err != nil {
+		return -1, err
+	}
+
+	return id, nil
+}
+
+// nextOccurrence возвращает следующее повторение задачи task, которое ещё не наступило к моменту now.
+// Срок выполнения и время напоминания сдвигаются по правилу повторения задачи, а разница между ними сохраняется.
+// Повторения задачи без срока и напоминания отсчитываются от момента now, и следующее повторение получает срок выполнения.
+// Если повторений больше нет, возвращает nil.
+func nextOccurrence(task models.Task, now time.Time) (*models.Task, error) {
+	rule, err := rrule.Parse(task.Recurrence)
+	if err != nil {
+		return nil, err
+	}
+
+	next := task
+	next.Id = 0
+	next.Completed = false
+	next.CompletedAt = nil
+	next.Tags = nil
+	next.Items = nil
+
+	// Опорная дата, от которой считаются повторения
+	anchor := now
+	switch {
+	case task.DueDate != nil:
+		anchor = *task.DueDate
+	case task.RemindAt != nil:
+		anchor = *task.RemindAt
+	}
+
+	nextAnchor := rule.Next(anchor)
+	for !nextAnchor.IsZero() && !nextAnchor.After(now) {
+		nextAnchor = rule.Next(nextAnchor)
+	}
+
+	if nextAnchor.IsZero() {
+		return nil, nil
+	}
+
+	shift := nextAnchor.Sub(anchor)
+	if task.DueDate != nil || task.RemindAt == nil {
+		due := nextAnchor
+		next.DueDate = &due
+	}
+	if task.RemindAt != nil {
+		remind := task.RemindAt.Add(shift)
+		next.RemindAt = &remind
+	}
+
+	return &next, nil
+}
+
+// logEvent записывает в историю задачи taskId событие event, совершённое пользователем actor.
+// Подробности события details сохраняются в формате JSON; nil, если подробностей нет.
+func logEvent(ctx context.Context, q querier, taskId int64, actor string, event models.TaskEventType, details any) error {
+	var detailsJson any
+	if details != nil {
+		b, err := json.Marshal(details)
+		if err != nil {
+			return err
+		}
+		detailsJson = string(b)
+	}
+
+	_, err := q.ExecContext(
+		ctx,
+		"INSERT INTO task_events(task_id, user_email, event, details) VALUES ($1, $2, $3, $4::jsonb)",
+		taskId, actor, event, detailsJson)
 	return err
 }
+
+// logged оборачивает запрос query, изменяющий задачи и возвращающий их id (RETURNING task_id), так, чтобы для каждой
+// изменённой задачи в историю записывалось событие event. actor - плейсхолдер параметра с email пользователя, например "$2".
+// Количество затронутых строк у обёрнутого запроса совпадает с количеством изменённых задач.
+func logged(query, actor string, event models.TaskEventType) string {
+	return fmt.Sprintf(
+		"WITH changed AS (%s) INSERT INTO task_events(task_id, user_email, event) SELECT task_id, %s, '%s' FROM changed",
+		query, actor, event)
+}
+
+// querier - общий интерфейс *sql.DB и *sql.Tx.
+type querier interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// checkTaskAccess возвращает sql.ErrNoRows, если задача с указанным id в корзине или недоступна пользователю userEmail
+// для изменения (write = true) или для просмотра (write = false).
+func checkTaskAccess(ctx context.Context, q querier, id int64, userEmail string, write bool) error {
+	cond := taskReadableBy("$2")
+	if write {
+		cond = taskWritableBy("$2")
+	}
+
+	var exists bool
+	return q.QueryRowContext(
+		ctx,
+		"SELECT true FROM tasks WHERE task_id = $1 AND "+cond+" AND deleted_at IS NULL",
+		id, userEmail).Scan(&exists)
+}
+
+// taskReadableBy возвращает SQL условие, которое истинно для задач, доступных пользователю userEmail для просмотра:
+// его собственных задач и задач из чужих списков, в которые он вступил.
+// Аргумент - плейсхолдер параметра запроса, например "$1".
+func taskReadableBy(userEmail string) string {
+	return fmt.Sprintf(
+		"(tasks.user_email = %[1]s OR tasks.list_id IN (SELECT list_id FROM list_members WHERE list_members.user_email = %[1]s AND accepted))",
+		userEmail)
+}
+
+// taskWritableBy возвращает SQL условие, которое истинно для задач, доступных пользователю userEmail для изменения:
+// его собственных задач и задач из чужих списков, в которых он редактор.
+// Аргумент - плейсхолдер параметра запроса, например "$1".
+func taskWritableBy(userEmail string) string {
+	return fmt.Sprintf(
+		"(tasks.user_email = %[1]s OR tasks.list_id IN (SELECT list_id FROM list_members WHERE list_members.user_email = %[1]s AND accepted AND role = '%[2]s'))",
+		userEmail, models.RoleEditor)
+}
+
+// listWritableBy возвращает SQL условие, которое истинно, если список listId не указан (NULL),
+// принадлежит пользователю userEmail или пользователь в нём редактор. Аргументы - плейсхолдеры параметров запроса.
+func listWritableBy(listId, userEmail string) string {
+	return fmt.Sprintf(
+		"(%[1]s::bigint IS NULL OR EXISTS (SELECT 1 FROM lists WHERE lists.list_id = %[1]s AND (lists.user_email = %[2]s"+
+			" OR lists.list_id IN (SELECT list_id FROM list_members WHERE list_members.user_email = %[2]s AND accepted AND role = '%[3]s'))))",
+		listId, userEmail, models.RoleEditor)
+}
+
+// listOwnedBy возвращает SQL условие, которое истинно, если список listId не указан (NULL)
+// или принадлежит пользователю userEmail. Аргументы - плейсхолдеры параметров запроса, например "$5".
+func listOwnedBy(listId, userEmail string) string {
+	return fmt.Sprintf(
+		"(%[1]s::bigint IS NULL OR EXISTS (SELECT 1 FROM lists WHERE lists.list_id = %[1]s AND lists.user_email = %[2]s))",
+		listId, userEmail)
+}
+
+// checkAffected возвращает sql.ErrNoRows, если запрос не затронул ни одной строки.
+func checkAffected(res sql.Result) error {
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
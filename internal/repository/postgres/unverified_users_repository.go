@@ -1,13 +1,16 @@
 package sqlite
 
 import (
+	"context"
 	"crypto/rand"
 	"database/sql"
 	"encoding/base32"
+	"errors"
 	"fmt"
 	"sync"
 
 	"github.com/artemwebber1/friendly_reminder/internal/models"
+	"github.com/lib/pq"
 )
 
 type UnverifiedUsersRepository struct {
@@ -88,6 +91,51 @@ func (r *UnverifiedUsersRepository) GetUserByToken(token string) (models.User, e
 	return user, nil
 }
 
+// ConfirmUser подтверждает почту пользователя по токену: добавляет его в таблицу users и удаляет токен.
+// Запросы других пользователей на смену почты на этот адрес перестают действовать. Возвращает почту пользователя.
+// Если токен не существует, возвращает sql.ErrNoRows, а если почта уже занята - models.ErrEmailTaken.
+func (r *UnverifiedUsersRepository) ConfirmUser(ctx context.Context, token string) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	var email, pwd string
+	err = tx.QueryRowContext(
+		ctx,
+		"DELETE FROM unverified_users WHERE token = $1 RETURNING user_email, user_password",
+		token).Scan(&email, &pwd)
+	if err != nil {
+		return "", err
+	}
+
+	// Почту могли занять сменой почты, пока регистрация ждала подтверждения
+	_, err = tx.ExecContext(ctx, "INSERT INTO users(email, password) VALUES($1, $2)", email, pwd)
+	if isUniqueViolation(err) {
+		return "", models.ErrEmailTaken
+	}
+	if err != nil {
+		return "", err
+	}
+
+	_, err = tx.ExecContext(ctx, "DELETE FROM email_changes WHERE new_email = $1", email)
+	if err != nil {
+		return "", err
+	}
+
+	return email, tx.Commit()
+}
+
+// isUniqueViolation возвращает true, если запрос не выполнен из-за нарушения ограничения уникальности.
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
+
 func generateToken() string {
 	tokBytes := make([]byte, 32)
 	rand.Read(tokBytes)
@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"sync"
+	"time"
 
 	"github.com/artemwebber1/friendly_reminder/internal/models"
 )
@@ -29,13 +30,99 @@ func (r *UsersRepository) AddUser(ctx context.Context, email, password string) e
 	return err
 }
 
-// DeleteUser удаляет пользователя из базы данных.
+// DeleteUser удаляет пользователя из базы данных вместе со всеми его задачами и их историей в одной транзакции.
+// Списки пользователя, участие в чужих списках, комментарии и сессии удаляются каскадно.
+// В истории задач других пользователей почта удалённого пользователя заменяется пустой строкой.
 func (r *UsersRepository) DeleteUser(ctx context.Context, email string) error {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
-	_, err := r.db.ExecContext(ctx, "DELETE FROM users WHERE email = $1", email)
-	return err
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, query := range []string{
+		"DELETE FROM task_events WHERE task_id IN (SELECT task_id FROM tasks WHERE user_email = $1)",
+		"UPDATE task_events SET user_email = '' WHERE user_email = $1",
+		"UPDATE tasks SET deleted_by = NULL WHERE deleted_by = $1",
+		"DELETE FROM tasks WHERE user_email = $1",
+		"DELETE FROM unverified_users WHERE user_email = $1",
+		"DELETE FROM users WHERE email = $1",
+	} {
+		_, err = tx.ExecContext(ctx, query, email)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// ExportData возвращает все списки, задачи и комментарии пользователя с указанной почтой.
+func (r *UsersRepository) ExportData(ctx context.Context, email string) (models.UserExport, error) {
+	export := models.UserExport{
+		Email:      email,
+		ExportedAt: time.Now(),
+		Lists:      make([]models.List, 0),
+		Comments:   make([]models.Comment, 0),
+	}
+
+	rows, err := r.db.QueryContext(
+		ctx,
+		"SELECT list_id, user_email, name, digest FROM lists WHERE user_email = $1 ORDER BY list_id",
+		email)
+	if err != nil {
+		return export, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		l := models.List{Role: models.RoleOwner}
+		err = rows.Scan(&l.Id, &l.UserEmail, &l.Name, &l.Digest)
+		if err != nil {
+			return export, err
+		}
+		export.Lists = append(export.Lists, l)
+	}
+	if err = rows.Err(); err != nil {
+		return export, err
+	}
+
+	taskRows, err := r.db.QueryContext(
+		ctx,
+		"SELECT "+taskColumns+" FROM tasks WHERE user_email = $1 ORDER BY position, task_id",
+		email)
+	if err != nil {
+		return export, err
+	}
+	defer taskRows.Close()
+
+	export.Tasks, err = scanTasks(taskRows)
+	if err != nil {
+		return export, err
+	}
+
+	commentRows, err := r.db.QueryContext(
+		ctx,
+		"SELECT comment_id, task_id, user_email, text, created_at FROM task_comments WHERE user_email = $1 ORDER BY comment_id",
+		email)
+	if err != nil {
+		return export, err
+	}
+	defer commentRows.Close()
+
+	for commentRows.Next() {
+		var c models.Comment
+		err = commentRows.Scan(&c.Id, &c.TaskId, &c.UserEmail, &c.Text, &c.CreatedAt)
+		if err != nil {
+			return export, err
+		}
+		export.Comments = append(export.Comments, c)
+	}
+
+	return export, commentRows.Err()
 }
 
 // Subscribe подписывает пользователя на рассылку электронных писем.
@@ -44,15 +131,85 @@ func (r *UsersRepository) Subscribe(ctx context.Context, email string, subscribe
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
-	_, err := r.db.ExecContext(ctx, "UPDATE users SET subscribed = $1 WHERE email = $2", subscribe, email)
+	_, err := r.db.ExecContext(ctx, "UPDATE users SET subscribed = $1, next_digest_at = NULL WHERE email = $2", subscribe, email)
 	return err
 }
 
+// SetDigestSchedule устанавливает пользователю расписание рассылки списка дел в виде cron выражения и часовой пояс,
+// в котором это расписание вычисляется. Пустое расписание означает рассылку с интервалом по умолчанию.
+// Запланированная ранее отправка сбрасывается.
+func (r *UsersRepository) SetDigestSchedule(ctx context.Context, email, schedule, timeZone string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	_, err := r.db.ExecContext(
+		ctx,
+		"UPDATE users SET digest_schedule = $1, time_zone = $2, next_digest_at = NULL WHERE email = $3",
+		schedule, timeZone, email)
+	return err
+}
+
+// SetDigestGroup устанавливает способ группировки задач в письме со списком дел пользователя.
+func (r *UsersRepository) SetDigestGroup(ctx context.Context, email string, group models.DigestGroup) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	_, err := r.db.ExecContext(ctx, "UPDATE users SET digest_group = $1 WHERE email = $2", group, email)
+	return err
+}
+
+// SetNextDigest устанавливает время следующей отправки списка дел пользователю.
+func (r *UsersRepository) SetNextDigest(ctx context.Context, email string, t time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	_, err := r.db.ExecContext(ctx, "UPDATE users SET next_digest_at = $1 WHERE email = $2", t, email)
+	return err
+}
+
+// SetLastDigest устанавливает время последней отправки списка дел пользователю.
+func (r *UsersRepository) SetLastDigest(ctx context.Context, email string, t time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	_, err := r.db.ExecContext(ctx, "UPDATE users SET last_digest_at = $1 WHERE email = $2", t, email)
+	return err
+}
+
+// GetDigestsDue возвращает подписанных на рассылку пользователей, которым к моменту now пора отправить список дел,
+// а также пользователей, для которых отправка ещё не запланирована.
+func (r *UsersRepository) GetDigestsDue(ctx context.Context, now time.Time) ([]models.User, error) {
+	rows, err := r.db.QueryContext(
+		ctx,
+		`SELECT email, COALESCE(digest_schedule, ''), COALESCE(time_zone, ''), digest_group, next_digest_at, last_digest_at FROM users
+		WHERE subscribed = true AND (next_digest_at IS NULL OR next_digest_at <= $1)`,
+		now)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := make([]models.User, 0)
+	for rows.Next() {
+		u := models.User{Subscribed: true}
+		err = rows.Scan(&u.Email, &u.DigestSchedule, &u.TimeZone, &u.DigestGroup, &u.NextDigestAt, &u.LastDigestAt)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, u)
+	}
+
+	return users, rows.Err()
+}
+
 func (r *UsersRepository) GetByEmail(ctx context.Context, email string) (*models.User, error) {
-	row := r.db.QueryRowContext(ctx, "SELECT email, password, subscribed FROM users WHERE email = $1", email)
+	row := r.db.QueryRowContext(
+		ctx,
+		"SELECT email, password, subscribed, COALESCE(digest_schedule, ''), COALESCE(time_zone, ''), digest_group FROM users WHERE email = $1",
+		email)
 
 	var u models.User
-	err := row.Scan(&u.Email, &u.Password, &u.Subscribed)
+	err := row.Scan(&u.Email, &u.Password, &u.Subscribed, &u.DigestSchedule, &u.TimeZone, &u.DigestGroup)
 	if err != nil {
 		return nil, err
 	}
@@ -86,8 +243,19 @@ func (r *UsersRepository) EmailExists(ctx context.Context, email string) bool {
 	return row.Scan() != sql.ErrNoRows
 }
 
-// UserExists возвращает true, если существует пользователь с указанной почтой и паролем.
-func (r *UsersRepository) UserExists(ctx context.Context, email, password string) bool {
-	row := r.db.QueryRowContext(ctx, "SELECT email, password FROM users WHERE email = $1 AND password = $2", email, password)
-	return row.Scan() != sql.ErrNoRows
+// GetPasswordHash возвращает хэш пароля пользователя с указанной почтой.
+// Если такого пользователя нет, возвращает sql.ErrNoRows.
+func (r *UsersRepository) GetPasswordHash(ctx context.Context, email string) (string, error) {
+	var hash string
+	err := r.db.QueryRowContext(ctx, "SELECT password FROM users WHERE email = $1", email).Scan(&hash)
+	return hash, err
+}
+
+// SetPasswordHash заменяет хэш пароля пользователя с указанной почтой.
+func (r *UsersRepository) SetPasswordHash(ctx context.Context, email, hash string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	_, err := r.db.ExecContext(ctx, "UPDATE users SET password = $1 WHERE email = $2", hash, email)
+	return err
 }
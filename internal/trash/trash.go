@@ -0,0 +1,49 @@
+package trash
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// Purger представляет собой объект, который в отдельной горутине
+// окончательно удаляет задачи, пролежавшие в корзине дольше срока хранения.
+type Purger interface {
+	// StartPurging с указанным интервалом d удаляет из корзины задачи, срок хранения которых истёк.
+	StartPurging(ctx context.Context, d time.Duration)
+}
+
+type tasksRepository interface {
+	PurgeTrash(ctx context.Context, before time.Time) (int64, error)
+}
+
+type defaultPurger struct {
+	tasksRepo tasksRepository
+	retention time.Duration // Сколько задачи хранятся в корзине
+}
+
+func NewPurger(tr tasksRepository, retention time.Duration) Purger {
+	return &defaultPurger{
+		tasksRepo: tr,
+		retention: retention,
+	}
+}
+
+// StartPurging с указанным интервалом d удаляет из корзины задачи, срок хранения которых истёк.
+func (p *defaultPurger) StartPurging(ctx context.Context, d time.Duration) {
+	for {
+		n, err := p.tasksRepo.PurgeTrash(ctx, time.Now().Add(-p.retention))
+		if err != nil {
+			log.Println(err)
+		} else if n > 0 {
+			log.Printf("Purged %d tasks from trash", n)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(d):
+			continue
+		}
+	}
+}
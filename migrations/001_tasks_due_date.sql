@@ -0,0 +1,4 @@
+-- Срок выполнения задачи. NULL, если срок не указан.
+ALTER TABLE tasks ADD COLUMN due_date TIMESTAMPTZ;
+
+CREATE INDEX tasks_user_email_due_date_idx ON tasks(user_email, due_date);
@@ -0,0 +1,7 @@
+-- Время, в которое пользователю нужно напомнить о задаче. NULL, если напоминание не нужно.
+ALTER TABLE tasks ADD COLUMN remind_at TIMESTAMPTZ;
+
+-- true, если напоминание о задаче уже отправлено.
+ALTER TABLE tasks ADD COLUMN reminder_sent BOOLEAN NOT NULL DEFAULT false;
+
+CREATE INDEX tasks_pending_reminders_idx ON tasks(remind_at) WHERE reminder_sent = false;
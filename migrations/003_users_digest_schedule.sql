@@ -0,0 +1,8 @@
+-- Cron выражение, по которому пользователю присылается список дел. NULL - рассылка с интервалом по умолчанию.
+ALTER TABLE users ADD COLUMN digest_schedule TEXT;
+
+-- Часовой пояс IANA, в котором вычисляется расписание.
+ALTER TABLE users ADD COLUMN time_zone TEXT;
+
+-- Время следующей отправки списка дел. NULL - отправка ещё не запланирована.
+ALTER TABLE users ADD COLUMN next_digest_at TIMESTAMPTZ;
@@ -0,0 +1,5 @@
+-- true, если задача выполнена.
+ALTER TABLE tasks ADD COLUMN completed BOOLEAN NOT NULL DEFAULT false;
+
+-- Время выполнения задачи. NULL, если задача не выполнена.
+ALTER TABLE tasks ADD COLUMN completed_at TIMESTAMPTZ;
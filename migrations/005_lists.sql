@@ -0,0 +1,13 @@
+-- Именованные списки задач пользователя.
+CREATE TABLE lists (
+    list_id    BIGSERIAL PRIMARY KEY,
+    user_email TEXT NOT NULL REFERENCES users(email) ON DELETE CASCADE,
+    name       TEXT NOT NULL,
+    digest     BOOLEAN NOT NULL DEFAULT true, -- true, если задачи из списка присылаются в рассылке
+    UNIQUE (user_email, name)
+);
+
+-- Список, к которому относится задача. NULL - список пользователя по умолчанию.
+ALTER TABLE tasks ADD COLUMN list_id BIGINT REFERENCES lists(list_id) ON DELETE CASCADE;
+
+CREATE INDEX tasks_list_id_idx ON tasks(list_id);
@@ -0,0 +1,5 @@
+-- Приоритет задачи: -1 - низкий, 0 - обычный, 1 - высокий.
+ALTER TABLE tasks ADD COLUMN priority SMALLINT NOT NULL DEFAULT 0;
+
+-- Время создания задачи. Для уже существующих задач равно времени миграции.
+ALTER TABLE tasks ADD COLUMN created_at TIMESTAMPTZ NOT NULL DEFAULT now();
@@ -0,0 +1,12 @@
+-- Место задачи в списке пользователя, заданное самим пользователем.
+ALTER TABLE tasks ADD COLUMN position BIGINT NOT NULL DEFAULT 0;
+
+-- Изначально задачи расставляются по приоритету и времени создания.
+UPDATE tasks SET position = ordered.rn
+FROM (
+    SELECT task_id, row_number() OVER (PARTITION BY user_email ORDER BY priority DESC, created_at, task_id) AS rn
+    FROM tasks
+) AS ordered
+WHERE tasks.task_id = ordered.task_id;
+
+CREATE INDEX tasks_user_email_position_idx ON tasks(user_email, position);
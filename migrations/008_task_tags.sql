@@ -0,0 +1,11 @@
+-- Метки задач.
+CREATE TABLE task_tags (
+    task_id BIGINT NOT NULL REFERENCES tasks(task_id) ON DELETE CASCADE,
+    tag     TEXT NOT NULL,
+    PRIMARY KEY (task_id, tag)
+);
+
+CREATE INDEX task_tags_tag_idx ON task_tags(tag);
+
+-- Способ группировки задач в письме со списком дел: '' - без группировки, 'tag' - по меткам.
+ALTER TABLE users ADD COLUMN digest_group TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,2 @@
+-- Правило повторения задачи в формате RRULE. Пустая строка - задача не повторяется.
+ALTER TABLE tasks ADD COLUMN recurrence TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,10 @@
+-- Пункты чек-листа внутри задачи.
+CREATE TABLE task_items (
+    item_id  BIGSERIAL PRIMARY KEY,
+    task_id  BIGINT NOT NULL REFERENCES tasks(task_id) ON DELETE CASCADE,
+    value    TEXT NOT NULL,
+    done     BOOLEAN NOT NULL DEFAULT false,
+    position BIGINT NOT NULL
+);
+
+CREATE INDEX task_items_task_id_idx ON task_items(task_id, position);
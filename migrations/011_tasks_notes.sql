@@ -0,0 +1,2 @@
+-- Подробное описание задачи в формате Markdown.
+ALTER TABLE tasks ADD COLUMN notes TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,4 @@
+-- Время перемещения задачи в корзину. NULL - задача не удалена.
+ALTER TABLE tasks ADD COLUMN deleted_at TIMESTAMPTZ;
+
+CREATE INDEX tasks_deleted_at_idx ON tasks(deleted_at) WHERE deleted_at IS NOT NULL;
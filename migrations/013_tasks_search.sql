@@ -0,0 +1,10 @@
+-- Вектор полнотекстового поиска по тексту и описанию задачи.
+-- Текст разбирается по правилам и русского, и английского языков; совпадения в тексте задачи важнее совпадений в описании.
+ALTER TABLE tasks ADD COLUMN search_vector TSVECTOR GENERATED ALWAYS AS (
+    setweight(to_tsvector('russian', value), 'A') ||
+    setweight(to_tsvector('english', value), 'A') ||
+    setweight(to_tsvector('russian', notes), 'B') ||
+    setweight(to_tsvector('english', notes), 'B')
+) STORED;
+
+CREATE INDEX tasks_search_vector_idx ON tasks USING GIN (search_vector);
@@ -0,0 +1,11 @@
+-- История изменений задач. Записи только добавляются и удаляются вместе с задачей.
+CREATE TABLE task_events (
+    event_id   BIGSERIAL PRIMARY KEY,
+    task_id    BIGINT NOT NULL REFERENCES tasks(task_id) ON DELETE CASCADE,
+    user_email TEXT NOT NULL, -- пользователь, совершивший действие
+    event      TEXT NOT NULL, -- created, edited, completed, reopened, deleted, restored
+    details    JSONB,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX task_events_task_id_idx ON task_events(task_id, event_id);
@@ -0,0 +1,10 @@
+-- Участники общих списков. Владелец списка хранится в lists.user_email и сюда не попадает.
+CREATE TABLE list_members (
+    list_id    BIGINT NOT NULL REFERENCES lists(list_id) ON DELETE CASCADE,
+    user_email TEXT NOT NULL REFERENCES users(email) ON DELETE CASCADE,
+    role       TEXT NOT NULL, -- viewer или editor
+    accepted   BOOLEAN NOT NULL DEFAULT false, -- true, если пользователь принял приглашение
+    PRIMARY KEY (list_id, user_email)
+);
+
+CREATE INDEX list_members_user_email_idx ON list_members(user_email);
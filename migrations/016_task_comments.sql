@@ -0,0 +1,13 @@
+-- Комментарии к задачам.
+CREATE TABLE task_comments (
+    comment_id BIGSERIAL PRIMARY KEY,
+    task_id    BIGINT NOT NULL REFERENCES tasks(task_id) ON DELETE CASCADE,
+    user_email TEXT NOT NULL REFERENCES users(email) ON DELETE CASCADE, -- автор комментария
+    text       TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX task_comments_task_id_idx ON task_comments(task_id, comment_id);
+
+-- Время последней отправки списка дел. В рассылке показываются задачи, прокомментированные после него.
+ALTER TABLE users ADD COLUMN last_digest_at TIMESTAMPTZ;
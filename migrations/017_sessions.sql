@@ -0,0 +1,11 @@
+-- Сессии пользователей. Каждому входу соответствует сессия со своим refresh токеном,
+-- id сессии записывается в access токены, выданные в рамках неё.
+CREATE TABLE sessions (
+    session_id   BIGSERIAL PRIMARY KEY,
+    user_email   TEXT NOT NULL REFERENCES users(email) ON DELETE CASCADE,
+    refresh_hash TEXT NOT NULL UNIQUE, -- SHA-256 текущего refresh токена; при обновлении токена заменяется
+    expires_at   TIMESTAMPTZ NOT NULL, -- срок действия refresh токена
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX sessions_user_email_idx ON sessions(user_email);
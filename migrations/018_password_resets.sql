@@ -0,0 +1,8 @@
+-- Токены для сброса пароля. Токен одноразовый: удаляется после использования.
+CREATE TABLE password_resets (
+    token_hash TEXT PRIMARY KEY, -- SHA-256 токена; сам токен хранится только в письме пользователю
+    user_email TEXT NOT NULL REFERENCES users(email) ON DELETE CASCADE,
+    expires_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX password_resets_user_email_idx ON password_resets(user_email);
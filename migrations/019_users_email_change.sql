@@ -0,0 +1,26 @@
+-- Смена почты пользователя: ссылки на users(email) обновляются вместе с ней.
+-- Задачи не ссылаются на users внешним ключом, их почта обновляется отдельно при смене.
+ALTER TABLE lists DROP CONSTRAINT lists_user_email_fkey,
+    ADD CONSTRAINT lists_user_email_fkey FOREIGN KEY (user_email) REFERENCES users(email) ON DELETE CASCADE ON UPDATE CASCADE;
+
+ALTER TABLE list_members DROP CONSTRAINT list_members_user_email_fkey,
+    ADD CONSTRAINT list_members_user_email_fkey FOREIGN KEY (user_email) REFERENCES users(email) ON DELETE CASCADE ON UPDATE CASCADE;
+
+ALTER TABLE task_comments DROP CONSTRAINT task_comments_user_email_fkey,
+    ADD CONSTRAINT task_comments_user_email_fkey FOREIGN KEY (user_email) REFERENCES users(email) ON DELETE CASCADE ON UPDATE CASCADE;
+
+ALTER TABLE sessions DROP CONSTRAINT sessions_user_email_fkey,
+    ADD CONSTRAINT sessions_user_email_fkey FOREIGN KEY (user_email) REFERENCES users(email) ON DELETE CASCADE ON UPDATE CASCADE;
+
+ALTER TABLE password_resets DROP CONSTRAINT password_resets_user_email_fkey,
+    ADD CONSTRAINT password_resets_user_email_fkey FOREIGN KEY (user_email) REFERENCES users(email) ON DELETE CASCADE ON UPDATE CASCADE;
+
+-- Запросы на смену почты. Почта меняется, когда пользователь перейдёт по ссылке, отправленной на новый адрес.
+CREATE TABLE email_changes (
+    token_hash TEXT PRIMARY KEY, -- SHA-256 токена из письма
+    user_email TEXT NOT NULL REFERENCES users(email) ON DELETE CASCADE ON UPDATE CASCADE,
+    new_email  TEXT NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX email_changes_user_email_idx ON email_changes(user_email);
@@ -0,0 +1,3 @@
+-- Следующее повторение, созданное при выполнении повторяющейся задачи.
+-- Если задачу снова открыть и выполнить, новое повторение не создаётся.
+ALTER TABLE tasks ADD COLUMN next_task_id BIGINT REFERENCES tasks(task_id) ON DELETE SET NULL;
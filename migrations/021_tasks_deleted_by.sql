@@ -0,0 +1,9 @@
+-- Пользователь, переместивший задачу в корзину. Задачу из общего списка может удалить не её владелец,
+-- и отмена удаления должна возвращать только задачи, удалённые самим пользователем.
+ALTER TABLE tasks ADD COLUMN deleted_by TEXT;
+
+UPDATE tasks SET deleted_by = user_email WHERE deleted_at IS NOT NULL;
+
+-- Задачи удалённого списка перемещаются в корзину, а не удаляются вместе с ним.
+ALTER TABLE tasks DROP CONSTRAINT tasks_list_id_fkey,
+    ADD CONSTRAINT tasks_list_id_fkey FOREIGN KEY (list_id) REFERENCES lists(list_id) ON DELETE SET NULL;
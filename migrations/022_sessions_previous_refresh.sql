@@ -0,0 +1,3 @@
+-- Хэш предыдущего refresh токена сессии. Если уже заменённый токен предъявлен снова, он, скорее всего, украден,
+-- и сессия завершается.
+ALTER TABLE sessions ADD COLUMN previous_hash TEXT UNIQUE;
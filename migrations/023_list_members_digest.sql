@@ -0,0 +1,3 @@
+-- Рассылка задач из общего списка включается и выключается каждым участником для себя.
+-- lists.digest относится только к владельцу списка.
+ALTER TABLE list_members ADD COLUMN digest BOOLEAN NOT NULL DEFAULT true;
@@ -0,0 +1,5 @@
+-- Пользователь, установивший напоминание о задаче. Напоминание о задаче из общего списка
+-- приходит ему, а не владельцу списка.
+ALTER TABLE tasks ADD COLUMN remind_email TEXT;
+
+UPDATE tasks SET remind_email = user_email WHERE remind_at IS NOT NULL;
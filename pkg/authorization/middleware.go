@@ -1,11 +1,43 @@
 package authorization
 
 import (
+	"context"
+	"errors"
 	"net/http"
 	"os"
 )
 
+// SessionChecker проверяет, не завершена ли сессия, в рамках которой выдан токен.
+type SessionChecker interface {
+	// SessionActive возвращает true, если сессия с указанным id существует и не истекла.
+	SessionActive(ctx context.Context, id int64) bool
+}
+
+var sessions SessionChecker
+
+var (
+	errNoSession      = errors.New("token has no session")
+	errSessionRevoked = errors.New("session is revoked")
+)
+
+// SetSessionChecker задаёт объект, с помощью которого Middleware отклоняет токены завершённых сессий.
+// Должна быть вызвана до создания обработчиков с помощью Middleware.
+func SetSessionChecker(c SessionChecker) {
+	if c == nil {
+		panic("authorization: session checker is nil")
+	}
+	sessions = c
+}
+
+// Middleware пропускает к обработчику next только запросы с действующим access токеном незавершённой сессии.
+// Если проверка сессий не задана с помощью SetSessionChecker, вызывает панику: иначе токены завершённых сессий
+// принимались бы до истечения их срока действия.
 func Middleware(next http.HandlerFunc) http.HandlerFunc {
+	checker := sessions
+	if checker == nil {
+		panic("authorization: SetSessionChecker must be called before Middleware")
+	}
+
 	return func(w http.ResponseWriter, r *http.Request) {
 		auth := r.Header.Get("Authorization")
 		if len(auth) <= 8 {
@@ -15,12 +47,23 @@ func Middleware(next http.HandlerFunc) http.HandlerFunc {
 
 		tok := auth[7:]
 
-		_, err := ParseJWT(tok, []byte(os.Getenv("SECRET_STR")))
+		claims, err := GetClaims(tok, []byte(os.Getenv("SECRET_STR")))
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusForbidden)
 			return
 		}
 
+		sid, err := GetSessionId(claims)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+
+		if !checker.SessionActive(r.Context(), sid) {
+			http.Error(w, errSessionRevoked.Error(), http.StatusUnauthorized)
+			return
+		}
+
 		next(w, r)
 	}
 }
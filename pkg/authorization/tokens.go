@@ -0,0 +1,52 @@
+package authorization
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"strconv"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// NewAccessToken создаёт подписанный ключом key jwt токен пользователя email, выданный в рамках сессии sessionId
+// и действующий в течение ttl.
+func NewAccessToken(email string, sessionId int64, ttl time.Duration, key []byte) (string, error) {
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"sub": email,
+		"sid": strconv.FormatInt(sessionId, 10),
+		"iat": now.Unix(),
+		"exp": now.Add(ttl).Unix(),
+	}
+
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(key)
+}
+
+// GetSessionId возвращает id сессии, в рамках которой выдан токен.
+func GetSessionId(claims jwt.MapClaims) (int64, error) {
+	sid, ok := claims["sid"].(string)
+	if !ok {
+		return 0, errNoSession
+	}
+	return strconv.ParseInt(sid, 10, 64)
+}
+
+// NewRefreshToken создаёт случайный refresh токен. Возвращает сам токен, который отдаётся пользователю,
+// и его хэш, который хранится на сервере (см. HashToken).
+func NewRefreshToken() (token, hash string) {
+	b := make([]byte, 32)
+	rand.Read(b)
+
+	token = base64.RawURLEncoding.EncodeToString(b)
+	return token, HashToken(token)
+}
+
+// HashToken возвращает хэш случайного токена (refresh токена или токена из письма), который хранится на сервере
+// вместо самого токена. Токены случайные и длинные, поэтому соль и медленный хэш не нужны.
+func HashToken(token string) string {
+	h := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(h[:])
+}
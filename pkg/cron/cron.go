@@ -0,0 +1,174 @@
+package cron
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule - это расписание, заданное cron выражением из пяти полей:
+// минуты, часы, день месяца, месяц, день недели.
+//
+// Например, выражение "30 8 * * 1-5" означает "по будням в 08:30".
+type Schedule struct {
+	minute, hour, dom, month, dow uint64 // Битовые маски допустимых значений полей
+
+	// Если оба поля ограничены (не начинаются с '*', как '*' или '*/2'), день подходит, когда подходит любое из них.
+	domRestricted, dowRestricted bool
+}
+
+type field struct {
+	name     string
+	min, max int
+}
+
+var fields = [5]field{
+	{"minute", 0, 59},
+	{"hour", 0, 23},
+	{"day of month", 1, 31},
+	{"month", 1, 12},
+	{"day of week", 0, 7},
+}
+
+// Parse разбирает cron выражение из пяти полей.
+// Каждое поле может содержать '*', число, диапазон 'a-b', шаг '*/n' или 'a-b/n' и списки через запятую.
+// День недели 0 и 7 означает воскресенье.
+func Parse(expr string) (*Schedule, error) {
+	parts := strings.Fields(expr)
+	if len(parts) != len(fields) {
+		return nil, fmt.Errorf("cron expression must have %d fields, got %d", len(fields), len(parts))
+	}
+
+	var masks [5]uint64
+	for i, p := range parts {
+		m, err := parseField(p, fields[i])
+		if err != nil {
+			return nil, err
+		}
+		masks[i] = m
+	}
+
+	// Воскресенье может быть записано и как 0, и как 7
+	if masks[4]&(1<<7) != 0 {
+		masks[4] |= 1
+	}
+
+	return &Schedule{
+		minute:        masks[0],
+		hour:          masks[1],
+		dom:           masks[2],
+		month:         masks[3],
+		dow:           masks[4],
+		domRestricted: !strings.HasPrefix(parts[2], "*"),
+		dowRestricted: !strings.HasPrefix(parts[4], "*"),
+	}, nil
+}
+
+// Next возвращает ближайший после t момент времени, подходящий под расписание.
+// Расписание вычисляется в часовом поясе t.
+// Если такой момент не найден в течение пяти лет, возвращается нулевое время.
+func (s *Schedule) Next(t time.Time) time.Time {
+	loc := t.Location()
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+
+	for t.Before(limit) {
+		if !has(s.month, int(t.Month())) {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc)
+			continue
+		}
+
+		if !s.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+			continue
+		}
+
+		if !has(s.hour, t.Hour()) {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, loc)
+			continue
+		}
+
+		if !has(s.minute, t.Minute()) {
+			t = t.Add(time.Minute)
+			continue
+		}
+
+		return t
+	}
+
+	return time.Time{}
+}
+
+func (s *Schedule) dayMatches(t time.Time) bool {
+	domOk := has(s.dom, t.Day())
+	dowOk := has(s.dow, int(t.Weekday()))
+
+	if s.domRestricted && s.dowRestricted {
+		return domOk || dowOk
+	}
+	return domOk && dowOk
+}
+
+func has(mask uint64, v int) bool {
+	return mask&(1<<uint(v)) != 0
+}
+
+func parseField(s string, f field) (uint64, error) {
+	var mask uint64
+	for _, part := range strings.Split(s, ",") {
+		m, err := parseRange(part, f)
+		if err != nil {
+			return 0, err
+		}
+		mask |= m
+	}
+	return mask, nil
+}
+
+// parseRange разбирает одну часть поля: '*', 'n', 'a-b', с необязательным шагом '/step'.
+func parseRange(s string, f field) (uint64, error) {
+	rng, stepStr, hasStep := strings.Cut(s, "/")
+
+	step := 1
+	if hasStep {
+		var err error
+		step, err = strconv.Atoi(stepStr)
+		if err != nil || step <= 0 {
+			return 0, fmt.Errorf("invalid step %q in %s field", stepStr, f.name)
+		}
+	}
+
+	var lo, hi int
+	switch {
+	case rng == "*":
+		lo, hi = f.min, f.max
+	case strings.Contains(rng, "-"):
+		a, b, _ := strings.Cut(rng, "-")
+		var err1, err2 error
+		lo, err1 = strconv.Atoi(a)
+		hi, err2 = strconv.Atoi(b)
+		if err1 != nil || err2 != nil {
+			return 0, fmt.Errorf("invalid range %q in %s field", rng, f.name)
+		}
+	default:
+		v, err := strconv.Atoi(rng)
+		if err != nil {
+			return 0, fmt.Errorf("invalid value %q in %s field", rng, f.name)
+		}
+		lo, hi = v, v
+		if hasStep {
+			hi = f.max
+		}
+	}
+
+	if lo < f.min || hi > f.max || lo > hi {
+		return 0, fmt.Errorf("value out of range [%d, %d] in %s field", f.min, f.max, f.name)
+	}
+
+	var mask uint64
+	for v := lo; v <= hi; v += step {
+		mask |= 1 << uint(v)
+	}
+	return mask, nil
+}
@@ -0,0 +1,53 @@
+package cron
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCronNext(t *testing.T) {
+	moscow, err := time.LoadLocation("Europe/Moscow")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Пятница, 10:00 по Москве
+	from := time.Date(2025, time.March, 7, 10, 0, 0, 0, moscow)
+
+	cases := []struct {
+		expr string
+		want time.Time
+	}{
+		{"30 8 * * 1-5", time.Date(2025, time.March, 10, 8, 30, 0, 0, moscow)},
+		{"*/15 * * * *", time.Date(2025, time.March, 7, 10, 15, 0, 0, moscow)},
+		{"0 9 1 * *", time.Date(2025, time.April, 1, 9, 0, 0, 0, moscow)},
+		{"0 12 * * 0", time.Date(2025, time.March, 9, 12, 0, 0, 0, moscow)},
+		{"0 12 * * 7", time.Date(2025, time.March, 9, 12, 0, 0, 0, moscow)},
+		// Если ограничены и день месяца, и день недели, подходит любой из них
+		{"0 9 1 * 1", time.Date(2025, time.March, 10, 9, 0, 0, 0, moscow)},
+		// Поле, начинающееся с '*', не считается ограниченным: нужны нечётные числа, приходящиеся на понедельник
+		{"0 9 */2 * 1", time.Date(2025, time.March, 17, 9, 0, 0, 0, moscow)},
+	}
+
+	for _, c := range cases {
+		sched, err := Parse(c.expr)
+		if err != nil {
+			t.Fatalf("%s: %s", c.expr, err)
+		}
+
+		got := sched.Next(from)
+		if !got.Equal(c.want) {
+			t.Errorf("%s: wanted %s, got %s", c.expr, c.want, got)
+		}
+	}
+}
+
+func TestCronParse_Invalid(t *testing.T) {
+	exprs := []string{"", "* * * *", "60 * * * *", "* 24 * * *", "* * 0 * *", "*/0 * * * *", "a * * * *", "5-1 * * * *"}
+
+	for _, expr := range exprs {
+		if _, err := Parse(expr); err == nil {
+			t.Errorf("Expected error for expression %q", expr)
+		}
+	}
+}
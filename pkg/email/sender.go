@@ -4,6 +4,7 @@ import (
 	"encoding/base64"
 	"fmt"
 	"net/smtp"
+	"strings"
 )
 
 // Sender позволяет отправлять электронные письма с конкретного адреса.
@@ -29,6 +30,21 @@ func NewSender(from, password, host, port string) Sender {
 	}
 }
 
+// encodeBody кодирует тело письма в base64 строками по 76 символов (RFC 2045),
+// чтобы длинные письма не превышали ограничение SMTP на длину строки.
+func encodeBody(body string) string {
+	const lineLen = 76
+
+	enc := base64.StdEncoding.EncodeToString([]byte(body))
+	var b strings.Builder
+	for len(enc) > lineLen {
+		b.WriteString(enc[:lineLen] + "\r\n")
+		enc = enc[lineLen:]
+	}
+	b.WriteString(enc)
+	return b.String()
+}
+
 func (s *defaultSender) Send(subject, body, to string) error {
 	msg := fmt.Appendf(
 		nil,
@@ -38,7 +54,7 @@ func (s *defaultSender) Send(subject, body, to string) error {
 			"Content-Type: text/plain; charset=\"UTF-8\"\r\n"+
 			"Content-Transfer-Encoding: base64\r\n\r\n"+
 			"%s",
-		to, subject, base64.StdEncoding.EncodeToString([]byte(body)))
+		to, subject, encodeBody(body))
 
 	addr := s.host + ":" + s.port
 	err := smtp.SendMail(
@@ -0,0 +1,130 @@
+package markdown
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	headingRe  = regexp.MustCompile(`^#{1,6}\s+`)
+	ruleRe     = regexp.MustCompile(`^(\*\s*){3,}$|^(-\s*){3,}$|^(_\s*){3,}$`)
+	taskItemRe = regexp.MustCompile(`^[-*+]\s+\[([ xX])\]\s+`)
+	bulletRe   = regexp.MustCompile(`^[-*+]\s+`)
+	imageRe    = regexp.MustCompile(`!\[([^\]]*)\]\([^)]*\)`)
+	linkRe     = regexp.MustCompile(`\[([^\]]+)\]\(([^)\s]+)(?:\s+"[^"]*")?\)`)
+	autolinkRe = regexp.MustCompile(`<((?:https?|mailto):[^>\s]+)>`)
+	strongRe   = regexp.MustCompile(`\*\*(\S(?:.*?\S)?)\*\*|__(\S(?:.*?\S)?)__`)
+	emStarRe   = regexp.MustCompile(`\*(\S(?:.*?\S)?)\*`)
+	emUnderRe  = regexp.MustCompile(`(^|\W)_(\S(?:.*?\S)?)_(\W|$)`)
+	strikeRe   = regexp.MustCompile(`~~(\S(?:.*?\S)?)~~`)
+	escapeRe   = regexp.MustCompile(`\\[\\*_{}\[\]()#+\-.!~>|]`)
+)
+
+// escapeBase - начало области частного использования Unicode. Экранированный символ c
+// на время обработки заменяется на escapeBase + c, чтобы он не считался разметкой.
+const escapeBase = 0xE000
+
+// ToText преобразует текст в формате Markdown в простой текст, пригодный для писем в формате text/plain.
+//
+// Разметка выделения, заголовков и блоков кода убирается, ссылки записываются как "текст (адрес)",
+// маркеры списков заменяются на "•", а пункты списка задач - на "[ ]" и "[x]".
+// Содержимое блоков кода и фрагментов кода остаётся без изменений.
+func ToText(s string) string {
+	lines := strings.Split(strings.ReplaceAll(s, "\r\n", "\n"), "\n")
+	out := make([]string, 0, len(lines))
+
+	inCode := false
+	for _, line := range lines {
+		if isFence(line) {
+			inCode = !inCode
+			continue
+		}
+
+		if inCode {
+			out = append(out, line)
+			continue
+		}
+
+		out = append(out, lineToText(line))
+	}
+
+	return strings.Trim(strings.Join(out, "\n"), "\n")
+}
+
+func isFence(line string) bool {
+	trimmed := strings.TrimSpace(line)
+	return strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~")
+}
+
+// lineToText преобразует одну строку вне блока кода.
+func lineToText(line string) string {
+	rest := strings.TrimLeft(line, " \t")
+	indent := line[:len(line)-len(rest)]
+
+	if ruleRe.MatchString(strings.TrimSpace(rest)) {
+		return indent + "----------"
+	}
+
+	switch {
+	case headingRe.MatchString(rest):
+		rest = strings.TrimRight(headingRe.ReplaceAllString(rest, ""), " #")
+	case taskItemRe.MatchString(rest):
+		mark := "[ ] "
+		if m := taskItemRe.FindStringSubmatch(rest); strings.ToLower(m[1]) == "x" {
+			mark = "[x] "
+		}
+		rest = mark + taskItemRe.ReplaceAllString(rest, "")
+	case bulletRe.MatchString(rest):
+		rest = "• " + bulletRe.ReplaceAllString(rest, "")
+	}
+
+	return indent + inlineToText(rest)
+}
+
+// inlineToText убирает строчную разметку. Фрагменты кода в обратных кавычках не изменяются.
+func inlineToText(s string) string {
+	parts := strings.Split(s, "`")
+	// Если кавычек нечётное число, последняя из них не закрывает фрагмент кода и остаётся как есть
+	unclosed := len(parts)%2 == 0
+
+	var b strings.Builder
+	for i, p := range parts {
+		switch {
+		case i%2 == 0:
+			b.WriteString(emphasisToText(p))
+		case unclosed && i == len(parts)-1:
+			b.WriteString("`" + emphasisToText(p))
+		default:
+			b.WriteString(p)
+		}
+	}
+	return b.String()
+}
+
+func emphasisToText(s string) string {
+	s = escapeRe.ReplaceAllStringFunc(s, func(m string) string {
+		return string(rune(escapeBase + int(m[1])))
+	})
+
+	s = imageRe.ReplaceAllString(s, "$1")
+	s = linkRe.ReplaceAllStringFunc(s, func(m string) string {
+		sub := linkRe.FindStringSubmatch(m)
+		if sub[1] == sub[2] {
+			return sub[2]
+		}
+		return sub[1] + " (" + sub[2] + ")"
+	})
+	s = autolinkRe.ReplaceAllString(s, "$1")
+
+	s = strongRe.ReplaceAllString(s, "$1$2")
+	s = emStarRe.ReplaceAllString(s, "$1")
+	s = emUnderRe.ReplaceAllString(s, "$1$2$3")
+	s = strikeRe.ReplaceAllString(s, "$1")
+
+	return strings.Map(func(r rune) rune {
+		if r >= escapeBase && r < escapeBase+128 {
+			return r - escapeBase
+		}
+		return r
+	}, s)
+}
@@ -0,0 +1,31 @@
+package markdown
+
+import (
+	"testing"
+)
+
+func TestMarkdownToText(t *testing.T) {
+	cases := []struct {
+		md, want string
+	}{
+		{"# Title", "Title"},
+		{"**bold**, *italic*, _also italic_ and ~~struck~~", "bold, italic, also italic and struck"},
+		{"snake_case_name stays", "snake_case_name stays"},
+		{"See [docs](https://example.com)", "See docs (https://example.com)"},
+		{"<https://example.com>", "https://example.com"},
+		{"![logo](logo.png)", "logo"},
+		{"- milk\n* bread", "• milk\n• bread"},
+		{"- [ ] todo\n- [x] done", "[ ] todo\n[x] done"},
+		{"Use `**raw**` here", "Use **raw** here"},
+		{"```\n# not a heading\n```", "# not a heading"},
+		{`\*not italic\*`, "*not italic*"},
+		{"a\n\n---\n\nb", "a\n\n----------\n\nb"},
+	}
+
+	for _, c := range cases {
+		got := ToText(c.md)
+		if got != c.want {
+			t.Errorf("%q: wanted %q, got %q", c.md, c.want, got)
+		}
+	}
+}
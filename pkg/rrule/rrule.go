@@ -0,0 +1,217 @@
+package rrule
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Frequency - базовый период повторения.
+type Frequency string
+
+const (
+	Daily   Frequency = "DAILY"
+	Weekly  Frequency = "WEEKLY"
+	Monthly Frequency = "MONTHLY"
+	Yearly  Frequency = "YEARLY"
+)
+
+// Rule - правило повторения в формате RRULE (RFC 5545).
+//
+// Поддерживается подмножество правила: FREQ (DAILY, WEEKLY, MONTHLY, YEARLY), INTERVAL,
+// BYDAY (только для WEEKLY), BYMONTHDAY (только для MONTHLY, отрицательные значения отсчитываются
+// от конца месяца) и UNTIL. Например:
+//
+//	FREQ=DAILY;INTERVAL=3       - каждые 3 дня
+//	FREQ=WEEKLY;BYDAY=MO,WE,FR  - по понедельникам, средам и пятницам
+//	FREQ=MONTHLY;BYMONTHDAY=-1  - в последний день каждого месяца
+type Rule struct {
+	Freq       Frequency
+	Interval   int
+	ByDay      []time.Weekday
+	ByMonthDay []int
+	Until      time.Time // Нулевое время означает, что повторения не ограничены.
+}
+
+var weekdays = map[string]time.Weekday{
+	"SU": time.Sunday,
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+}
+
+// Parse разбирает правило повторения. Префикс 'RRULE:' необязателен.
+func Parse(s string) (*Rule, error) {
+	s = strings.TrimPrefix(strings.TrimSpace(s), "RRULE:")
+	if s == "" {
+		return nil, errors.New("empty recurrence rule")
+	}
+
+	r := &Rule{Interval: 1}
+	for _, part := range strings.Split(s, ";") {
+		key, value, ok := strings.Cut(part, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid rule part %q", part)
+		}
+
+		switch strings.ToUpper(key) {
+		case "FREQ":
+			r.Freq = Frequency(strings.ToUpper(value))
+			switch r.Freq {
+			case Daily, Weekly, Monthly, Yearly:
+			default:
+				return nil, fmt.Errorf("unsupported FREQ %q", value)
+			}
+		case "INTERVAL":
+			n, err := strconv.Atoi(value)
+			if err != nil || n <= 0 {
+				return nil, fmt.Errorf("invalid INTERVAL %q", value)
+			}
+			r.Interval = n
+		case "BYDAY":
+			for _, d := range strings.Split(value, ",") {
+				wd, ok := weekdays[strings.ToUpper(d)]
+				if !ok {
+					return nil, fmt.Errorf("invalid BYDAY value %q", d)
+				}
+				r.ByDay = append(r.ByDay, wd)
+			}
+		case "BYMONTHDAY":
+			for _, d := range strings.Split(value, ",") {
+				n, err := strconv.Atoi(d)
+				if err != nil || n == 0 || n < -31 || n > 31 {
+					return nil, fmt.Errorf("invalid BYMONTHDAY value %q", d)
+				}
+				r.ByMonthDay = append(r.ByMonthDay, n)
+			}
+		case "UNTIL":
+			until, err := parseUntil(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid UNTIL %q", value)
+			}
+			r.Until = until
+		default:
+			return nil, fmt.Errorf("unsupported rule part %q", key)
+		}
+	}
+
+	if r.Freq == "" {
+		return nil, errors.New("FREQ is required")
+	}
+	if len(r.ByDay) > 0 && r.Freq != Weekly {
+		return nil, errors.New("BYDAY is supported only with FREQ=WEEKLY")
+	}
+	if len(r.ByMonthDay) > 0 && r.Freq != Monthly {
+		return nil, errors.New("BYMONTHDAY is supported only with FREQ=MONTHLY")
+	}
+
+	return r, nil
+}
+
+// Next возвращает следующее после t повторение. Время суток и часовой пояс берутся из t.
+// Если повторений больше нет (правило ограничено UNTIL), возвращается нулевое время.
+func (r *Rule) Next(t time.Time) time.Time {
+	var next time.Time
+	switch r.Freq {
+	case Daily:
+		next = t.AddDate(0, 0, r.Interval)
+	case Weekly:
+		next = r.nextWeekly(t)
+	case Monthly:
+		next = r.nextMonthly(t)
+	case Yearly:
+		next = addMonthsStrict(t, 12*r.Interval)
+	}
+
+	if !r.Until.IsZero() && next.After(r.Until) {
+		return time.Time{}
+	}
+	return next
+}
+
+func (r *Rule) nextWeekly(t time.Time) time.Time {
+	if len(r.ByDay) == 0 {
+		return t.AddDate(0, 0, 7*r.Interval)
+	}
+
+	start := weekStart(t)
+	for d := 1; d <= 7*(r.Interval+1); d++ {
+		c := t.AddDate(0, 0, d)
+		weeks := int(weekStart(c).Sub(start).Hours()+12) / (24 * 7)
+		if weeks%r.Interval == 0 && slices.Contains(r.ByDay, c.Weekday()) {
+			return c
+		}
+	}
+	return time.Time{}
+}
+
+func (r *Rule) nextMonthly(t time.Time) time.Time {
+	if len(r.ByMonthDay) == 0 {
+		return addMonthsStrict(t, r.Interval)
+	}
+
+	// Перебираем месяцы, начиная с текущего, и ищем ближайший подходящий день после t
+	for m := 0; m <= 12*r.Interval; m += r.Interval {
+		first := time.Date(t.Year(), t.Month()+time.Month(m), 1, t.Hour(), t.Minute(), t.Second(), 0, t.Location())
+		n := daysIn(first)
+
+		days := make([]int, 0, len(r.ByMonthDay))
+		for _, d := range r.ByMonthDay {
+			if d < 0 {
+				d = n + d + 1
+			}
+			if d >= 1 && d <= n {
+				days = append(days, d)
+			}
+		}
+		slices.Sort(days)
+
+		for _, d := range days {
+			c := first.AddDate(0, 0, d-1)
+			if c.After(t) {
+				return c
+			}
+		}
+	}
+	return time.Time{}
+}
+
+// addMonthsStrict прибавляет к t months месяцев, пропуская месяцы, в которых нет такого же числа
+// (например, 31 января + 1 месяц = 31 марта).
+func addMonthsStrict(t time.Time, months int) time.Time {
+	for k := 1; k <= 12; k++ {
+		c := time.Date(t.Year(), t.Month()+time.Month(months*k), t.Day(), t.Hour(), t.Minute(), t.Second(), 0, t.Location())
+		if c.Day() == t.Day() {
+			return c
+		}
+	}
+	return time.Time{}
+}
+
+// weekStart возвращает полночь понедельника недели, в которую входит t.
+func weekStart(t time.Time) time.Time {
+	offset := (int(t.Weekday()) + 6) % 7
+	return time.Date(t.Year(), t.Month(), t.Day()-offset, 0, 0, 0, 0, t.Location())
+}
+
+func daysIn(t time.Time) int {
+	return time.Date(t.Year(), t.Month()+1, 0, 0, 0, 0, 0, t.Location()).Day()
+}
+
+func parseUntil(s string) (time.Time, error) {
+	for _, layout := range []string{"20060102T150405Z", "20060102"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			if layout == "20060102" {
+				t = t.Add(24*time.Hour - time.Second) // Весь указанный день включительно
+			}
+			return t, nil
+		}
+	}
+	return time.Time{}, errors.New("invalid date")
+}
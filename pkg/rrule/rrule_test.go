@@ -0,0 +1,48 @@
+package rrule
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRRuleNext(t *testing.T) {
+	// Пятница, 31 января 2025, 09:00
+	from := time.Date(2025, time.January, 31, 9, 0, 0, 0, time.UTC)
+
+	cases := []struct {
+		rule string
+		want time.Time
+	}{
+		{"FREQ=DAILY;INTERVAL=3", time.Date(2025, time.February, 3, 9, 0, 0, 0, time.UTC)},
+		{"FREQ=WEEKLY", time.Date(2025, time.February, 7, 9, 0, 0, 0, time.UTC)},
+		{"FREQ=WEEKLY;BYDAY=MO,WE", time.Date(2025, time.February, 3, 9, 0, 0, 0, time.UTC)},
+		{"FREQ=WEEKLY;INTERVAL=2;BYDAY=MO", time.Date(2025, time.February, 10, 9, 0, 0, 0, time.UTC)},
+		{"RRULE:FREQ=MONTHLY", time.Date(2025, time.March, 31, 9, 0, 0, 0, time.UTC)},
+		{"FREQ=MONTHLY;BYMONTHDAY=-1", time.Date(2025, time.February, 28, 9, 0, 0, 0, time.UTC)},
+		{"FREQ=MONTHLY;BYMONTHDAY=1,15", time.Date(2025, time.February, 1, 9, 0, 0, 0, time.UTC)},
+		{"FREQ=YEARLY", time.Date(2026, time.January, 31, 9, 0, 0, 0, time.UTC)},
+		{"FREQ=DAILY;UNTIL=20250131", time.Time{}},
+	}
+
+	for _, c := range cases {
+		rule, err := Parse(c.rule)
+		if err != nil {
+			t.Fatalf("%s: %s", c.rule, err)
+		}
+
+		got := rule.Next(from)
+		if !got.Equal(c.want) {
+			t.Errorf("%s: wanted %s, got %s", c.rule, c.want, got)
+		}
+	}
+}
+
+func TestRRuleParse_Invalid(t *testing.T) {
+	rules := []string{"", "INTERVAL=2", "FREQ=HOURLY", "FREQ=DAILY;INTERVAL=0", "FREQ=DAILY;BYDAY=MO", "FREQ=WEEKLY;BYDAY=XX", "FREQ=MONTHLY;BYMONTHDAY=32"}
+
+	for _, rule := range rules {
+		if _, err := Parse(rule); err == nil {
+			t.Errorf("Expected error for rule %q", rule)
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/artemwebber1/friendly_reminder/internal/models"
+	repo "github.com/artemwebber1/friendly_reminder/internal/repository/postgres"
+)
+
+func TestComments(t *testing.T) {
+	defer cleanDb(db, t)
+
+	usersRepo := repo.NewUsersRepository(db)
+	usersRepo.AddUser(t.Context(), mock.email, mock.pwd)
+	usersRepo.AddUser(t.Context(), otherMock.email, otherMock.pwd)
+
+	tasksRepo := repo.NewTasksRepository(db)
+	created, err := tasksRepo.AddTask(t.Context(), models.Task{Value: "smth", UserEmail: mock.email})
+	if err != nil {
+		t.Fatal(err)
+	}
+	taskId := created.Id
+
+	commentsRepo := repo.NewCommentsRepository(db)
+	comment, err := commentsRepo.AddComment(t.Context(), models.Comment{TaskId: taskId, UserEmail: mock.email, Text: "first"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = commentsRepo.AddComment(t.Context(), models.Comment{TaskId: taskId, UserEmail: otherMock.email, Text: "other"})
+	if err == nil {
+		t.Fatal("Comment was added to another user's task")
+	}
+
+	comments, err := commentsRepo.GetComments(t.Context(), taskId, mock.email)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(comments) != 1 || comments[0].Text != "first" || comments[0].UserEmail != mock.email {
+		t.Fatalf("Wanted one comment 'first', got %v", comments)
+	}
+
+	_, err = commentsRepo.GetComments(t.Context(), taskId, otherMock.email)
+	if err == nil {
+		t.Fatal("Comments of another user's task are visible")
+	}
+
+	err = commentsRepo.DeleteComment(t.Context(), taskId, comment.Id, otherMock.email)
+	if err == nil {
+		t.Fatal("Comment was deleted by another user")
+	}
+
+	err = commentsRepo.DeleteComment(t.Context(), taskId, comment.Id, mock.email)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Здесь тестируем подсчёт новых комментариев участника общего списка для рассылки.
+func TestCountNewComments(t *testing.T) {
+	defer cleanDb(db, t)
+
+	usersRepo := repo.NewUsersRepository(db)
+	usersRepo.AddUser(t.Context(), mock.email, mock.pwd)
+	usersRepo.AddUser(t.Context(), otherMock.email, otherMock.pwd)
+
+	listsRepo := repo.NewListsRepository(db)
+	listId, err := listsRepo.AddList(t.Context(), models.List{UserEmail: mock.email, Name: "Family", Digest: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = listsRepo.InviteMember(t.Context(), mock.email, models.ListMember{ListId: listId, UserEmail: otherMock.email, Role: models.RoleViewer})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = listsRepo.AcceptInvite(t.Context(), listId, otherMock.email)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tasksRepo := repo.NewTasksRepository(db)
+	created, err := tasksRepo.AddTask(t.Context(), models.Task{Value: "smth", UserEmail: mock.email, ListId: &listId})
+	if err != nil {
+		t.Fatal(err)
+	}
+	taskId := created.Id
+
+	since := time.Now().Add(-time.Minute)
+
+	commentsRepo := repo.NewCommentsRepository(db)
+	for _, c := range []models.Comment{
+		{TaskId: taskId, UserEmail: otherMock.email, Text: "first"},
+		{TaskId: taskId, UserEmail: otherMock.email, Text: "second"},
+		{TaskId: taskId, UserEmail: mock.email, Text: "own"},
+	} {
+		_, err = commentsRepo.AddComment(t.Context(), c)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	counts, err := commentsRepo.CountNewComments(t.Context(), mock.email, since)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(counts) != 1 || counts[taskId] != 2 {
+		t.Fatalf("Wanted 2 new comments from other user, got %v", counts)
+	}
+
+	counts, err = commentsRepo.CountNewComments(t.Context(), mock.email, time.Now().Add(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(counts) != 0 {
+		t.Fatalf("Wanted no new comments, got %v", counts)
+	}
+
+	// Комментарии к задачам в корзине не учитываются
+	err = tasksRepo.DeleteTask(t.Context(), taskId, mock.email)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	counts, err = commentsRepo.CountNewComments(t.Context(), mock.email, since)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(counts) != 0 {
+		t.Fatalf("Wanted no new comments on trashed task, got %v", counts)
+	}
+}
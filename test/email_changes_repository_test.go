@@ -0,0 +1,90 @@
+package test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/artemwebber1/friendly_reminder/internal/hasher"
+	"github.com/artemwebber1/friendly_reminder/internal/models"
+	repo "github.com/artemwebber1/friendly_reminder/internal/repository/postgres"
+)
+
+// Здесь тестируем смену почты: задачи и списки пользователя должны перейти на новую почту.
+func TestChangeEmail(t *testing.T) {
+	defer cleanDb(db, t)
+
+	usersRepo := repo.NewUsersRepository(db)
+	usersRepo.AddUser(t.Context(), mock.email, hasher.Hash(mock.pwd))
+
+	listsRepo := repo.NewListsRepository(db)
+	listId, err := listsRepo.AddList(t.Context(), models.List{UserEmail: mock.email, Name: "Work", Digest: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tasksRepo := repo.NewTasksRepository(db)
+	_, err = tasksRepo.AddTask(t.Context(), models.Task{Value: "smth", UserEmail: mock.email, ListId: &listId})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const newEmail = "new@mail.com"
+	changesRepo := repo.NewEmailChangesRepository(db)
+	token, err := changesRepo.CreateEmailChange(t.Context(), mock.email, newEmail, time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	oldEmail, gotEmail, err := changesRepo.ChangeEmail(t.Context(), token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if oldEmail != mock.email || gotEmail != newEmail {
+		t.Fatalf("Wanted email change from '%s' to '%s', got '%s' to '%s'", mock.email, newEmail, oldEmail, gotEmail)
+	}
+
+	if usersRepo.EmailExists(t.Context(), mock.email) || !usersRepo.EmailExists(t.Context(), newEmail) {
+		t.Fatal("Email of user was not changed")
+	}
+
+	list, err := tasksRepo.GetList(t.Context(), newEmail, models.ListOptions{ListId: &listId})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 1 || list[0].UserEmail != newEmail {
+		t.Fatalf("Tasks were not moved to new email, got %v", list)
+	}
+
+	lists, err := listsRepo.GetLists(t.Context(), newEmail)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lists) != 1 {
+		t.Fatalf("Lists were not moved to new email, got %v", lists)
+	}
+
+	_, _, err = changesRepo.ChangeEmail(t.Context(), token)
+	if err == nil {
+		t.Fatal("Confirm token was used twice")
+	}
+}
+
+func TestChangeEmail_Taken(t *testing.T) {
+	defer cleanDb(db, t)
+
+	usersRepo := repo.NewUsersRepository(db)
+	usersRepo.AddUser(t.Context(), mock.email, hasher.Hash(mock.pwd))
+	usersRepo.AddUser(t.Context(), otherMock.email, hasher.Hash(otherMock.pwd))
+
+	changesRepo := repo.NewEmailChangesRepository(db)
+	token, err := changesRepo.CreateEmailChange(t.Context(), mock.email, otherMock.email, time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, _, err = changesRepo.ChangeEmail(t.Context(), token)
+	if !errors.Is(err, models.ErrEmailTaken) {
+		t.Fatalf("Wanted models.ErrEmailTaken when changing email to email of another user, got %v", err)
+	}
+}
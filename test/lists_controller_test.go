@@ -0,0 +1,73 @@
+package test
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/artemwebber1/friendly_reminder/internal/hasher"
+	"github.com/artemwebber1/friendly_reminder/internal/models"
+	repo "github.com/artemwebber1/friendly_reminder/internal/repository/postgres"
+)
+
+func TestCreateList(t *testing.T) {
+	defer cleanDb(db, t)
+
+	usersRepo := repo.NewUsersRepository(db)
+	usersRepo.AddUser(t.Context(), mock.email, hasher.Hash(mock.pwd))
+
+	body := bytes.NewReader([]byte(`{"name": "Groceries"}`))
+	req, err := http.NewRequest(http.MethodPost, addr+"/lists/new", body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Add("Authorization", "Bearer "+getJwt(t, getUsersController(db)))
+
+	resRec := httptest.NewRecorder()
+	listsCtrl := getListsController(db)
+	listsCtrl.CreateList(resRec, req)
+
+	if resRec.Result().StatusCode != http.StatusCreated {
+		t.Fatal(statusCodesMismatch(http.StatusCreated, resRec.Result().StatusCode, resRec.Body.String()))
+	}
+
+	lists, err := repo.NewListsRepository(db).GetLists(t.Context(), mock.email)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(lists) != 1 || lists[0].Name != "Groceries" || !lists[0].Digest {
+		t.Fatalf("List was not created: %v", lists)
+	}
+}
+
+func TestDeleteList_OtherUser(t *testing.T) {
+	defer cleanDb(db, t)
+
+	usersRepo := repo.NewUsersRepository(db)
+	usersRepo.AddUser(t.Context(), mock.email, hasher.Hash(mock.pwd))
+	usersRepo.AddUser(t.Context(), otherMock.email, hasher.Hash(otherMock.pwd))
+
+	listsRepo := repo.NewListsRepository(db)
+	listId, err := listsRepo.AddList(t.Context(), models.List{UserEmail: mock.email, Name: "Work", Digest: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req, err := http.NewRequest(http.MethodDelete, addr+"/lists/{id}", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.SetPathValue("id", strconv.FormatInt(listId, 10))
+	req.Header.Add("Authorization", "Bearer "+getJwtFor(t, getUsersController(db), otherMock))
+
+	resRec := httptest.NewRecorder()
+	listsCtrl := getListsController(db)
+	listsCtrl.DeleteList(resRec, req)
+
+	if resRec.Result().StatusCode != http.StatusNotFound {
+		t.Fatal(statusCodesMismatch(http.StatusNotFound, resRec.Result().StatusCode, resRec.Body.String()))
+	}
+}
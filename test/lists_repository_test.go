@@ -0,0 +1,482 @@
+package test
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/artemwebber1/friendly_reminder/internal/models"
+	repo "github.com/artemwebber1/friendly_reminder/internal/repository/postgres"
+)
+
+func TestGetList_ByList(t *testing.T) {
+	defer cleanDb(db, t)
+
+	usersRepo := repo.NewUsersRepository(db)
+	err := usersRepo.AddUser(t.Context(), mock.email, mock.pwd)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	listsRepo := repo.NewListsRepository(db)
+	workId, err := listsRepo.AddList(t.Context(), models.List{UserEmail: mock.email, Name: "Work", Digest: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	homeId, err := listsRepo.AddList(t.Context(), models.List{UserEmail: mock.email, Name: "Home", Digest: false})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tasksRepo := repo.NewTasksRepository(db)
+	tasks := []models.Task{
+		{Value: "default", UserEmail: mock.email},
+		{Value: "work", UserEmail: mock.email, ListId: &workId},
+		{Value: "home", UserEmail: mock.email, ListId: &homeId},
+	}
+	for _, task := range tasks {
+		_, err = tasksRepo.AddTask(t.Context(), task)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	work, err := tasksRepo.GetList(t.Context(), mock.email, models.ListOptions{ListId: &workId})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(work) != 1 || work[0].Value != "work" {
+		t.Fatalf("Wanted only task from list 'Work', got %v", work)
+	}
+
+	digest, err := tasksRepo.GetList(t.Context(), mock.email, models.ListOptions{DigestOnly: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, task := range digest {
+		if task.Value == "home" {
+			t.Fatal("Task from list without digest is included in digest")
+		}
+	}
+
+	if len(digest) != 2 {
+		t.Fatalf("Wanted 2 tasks in digest, got %v", digest)
+	}
+}
+
+// Здесь тестируем добавление задачи в чужой список - должна вернуться ошибка.
+func TestAddTask_OtherUsersList(t *testing.T) {
+	defer cleanDb(db, t)
+
+	usersRepo := repo.NewUsersRepository(db)
+	usersRepo.AddUser(t.Context(), mock.email, mock.pwd)
+	usersRepo.AddUser(t.Context(), otherMock.email, otherMock.pwd)
+
+	listsRepo := repo.NewListsRepository(db)
+	listId, err := listsRepo.AddList(t.Context(), models.List{UserEmail: mock.email, Name: "Work", Digest: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tasksRepo := repo.NewTasksRepository(db)
+	_, err = tasksRepo.AddTask(t.Context(), models.Task{Value: "smth", UserEmail: otherMock.email, ListId: &listId})
+	if err == nil {
+		t.Fatal("Task was added to another user's list")
+	}
+}
+
+func TestDeleteList(t *testing.T) {
+	defer cleanDb(db, t)
+
+	usersRepo := repo.NewUsersRepository(db)
+	usersRepo.AddUser(t.Context(), mock.email, mock.pwd)
+
+	listsRepo := repo.NewListsRepository(db)
+	listId, err := listsRepo.AddList(t.Context(), models.List{UserEmail: mock.email, Name: "Work", Digest: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tasksRepo := repo.NewTasksRepository(db)
+	_, err = tasksRepo.AddTask(t.Context(), models.Task{Value: "smth", UserEmail: mock.email, ListId: &listId})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = listsRepo.DeleteList(t.Context(), listId, mock.email)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	list, err := tasksRepo.GetList(t.Context(), mock.email, models.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(list) != 0 {
+		t.Fatal("Tasks of deleted list were not deleted")
+	}
+
+	// Задачи удалённого списка попадают в корзину и восстанавливаются в список по умолчанию
+	n, err := tasksRepo.UndoDelete(t.Context(), mock.email)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	list, err = tasksRepo.GetList(t.Context(), mock.email, models.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n != 1 || len(list) != 1 || list[0].ListId != nil {
+		t.Fatalf("Wanted task of deleted list restored to default list, got %v", list)
+	}
+}
+
+// Задачу из общего списка, удалённую редактором, редактор видит в корзине и может вернуть,
+// а отмена удаления владельцем списка её не затрагивает.
+func TestSharedList_Trash(t *testing.T) {
+	defer cleanDb(db, t)
+
+	usersRepo := repo.NewUsersRepository(db)
+	usersRepo.AddUser(t.Context(), mock.email, mock.pwd)
+	usersRepo.AddUser(t.Context(), otherMock.email, otherMock.pwd)
+
+	listsRepo := repo.NewListsRepository(db)
+	listId, err := listsRepo.AddList(t.Context(), models.List{UserEmail: mock.email, Name: "Family", Digest: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = listsRepo.InviteMember(t.Context(), mock.email, models.ListMember{ListId: listId, UserEmail: otherMock.email, Role: models.RoleEditor})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = listsRepo.AcceptInvite(t.Context(), listId, otherMock.email)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tasksRepo := repo.NewTasksRepository(db)
+	created, err := tasksRepo.AddTask(t.Context(), models.Task{Value: "buy milk", UserEmail: mock.email, ListId: &listId})
+	if err != nil {
+		t.Fatal(err)
+	}
+	taskId := created.Id
+
+	err = tasksRepo.DeleteTask(t.Context(), taskId, otherMock.email)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	trash, err := tasksRepo.GetTrash(t.Context(), otherMock.email)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(trash) != 1 || trash[0].Id != taskId {
+		t.Fatalf("Wanted deleted shared task in editor's trash, got %v", trash)
+	}
+
+	n, err := tasksRepo.UndoDelete(t.Context(), mock.email)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 0 {
+		t.Fatal("Owner's undo restored a task deleted by editor")
+	}
+
+	n, err = tasksRepo.UndoDelete(t.Context(), otherMock.email)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Fatal("Editor could not undo deletion of shared task")
+	}
+}
+
+// Здесь тестируем общий список: читатель видит задачи, но не может их менять, а редактор может.
+func TestSharedList(t *testing.T) {
+	defer cleanDb(db, t)
+
+	usersRepo := repo.NewUsersRepository(db)
+	usersRepo.AddUser(t.Context(), mock.email, mock.pwd)
+	usersRepo.AddUser(t.Context(), otherMock.email, otherMock.pwd)
+
+	listsRepo := repo.NewListsRepository(db)
+	listId, err := listsRepo.AddList(t.Context(), models.List{UserEmail: mock.email, Name: "Family", Digest: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tasksRepo := repo.NewTasksRepository(db)
+	created, err := tasksRepo.AddTask(t.Context(), models.Task{Value: "buy milk", UserEmail: mock.email, ListId: &listId})
+	if err != nil {
+		t.Fatal(err)
+	}
+	taskId := created.Id
+
+	member := models.ListMember{ListId: listId, UserEmail: otherMock.email, Role: models.RoleViewer}
+	_, err = listsRepo.InviteMember(t.Context(), mock.email, member)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Пока приглашение не принято, задачи списка не видны
+	list, err := tasksRepo.GetList(t.Context(), otherMock.email, models.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 0 {
+		t.Fatalf("Tasks are visible before accepting the invite: %v", list)
+	}
+
+	_, err = listsRepo.AcceptInvite(t.Context(), listId, otherMock.email)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	list, err = tasksRepo.GetList(t.Context(), otherMock.email, models.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 1 || list[0].Id != taskId {
+		t.Fatalf("Wanted shared task in member's list, got %v", list)
+	}
+
+	_, err = tasksRepo.SetCompleted(t.Context(), taskId, otherMock.email, true)
+	if err == nil {
+		t.Fatal("Viewer completed a task of shared list")
+	}
+
+	_, err = tasksRepo.AddTask(t.Context(), models.Task{Value: "buy bread", UserEmail: otherMock.email, ListId: &listId})
+	if err == nil {
+		t.Fatal("Viewer added a task to shared list")
+	}
+
+	member.Role = models.RoleEditor
+	_, err = listsRepo.InviteMember(t.Context(), mock.email, member)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = tasksRepo.SetCompleted(t.Context(), taskId, otherMock.email, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Задача, добавленная редактором, принадлежит владельцу списка
+	_, err = tasksRepo.AddTask(t.Context(), models.Task{Value: "buy bread", UserEmail: otherMock.email, ListId: &listId})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ownerList, err := tasksRepo.GetList(t.Context(), mock.email, models.ListOptions{ListId: &listId})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ownerList) != 1 || ownerList[0].UserEmail != mock.email {
+		t.Fatalf("Wanted task added by editor in owner's list, got %v", ownerList)
+	}
+
+	err = listsRepo.RemoveMember(t.Context(), listId, otherMock.email, otherMock.email)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	list, err = tasksRepo.GetList(t.Context(), otherMock.email, models.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 0 {
+		t.Fatalf("Tasks are visible after leaving the list: %v", list)
+	}
+}
+
+// Рассылку общего списка каждый участник включает и выключает для себя, независимо от владельца.
+func TestSharedList_Digest(t *testing.T) {
+	defer cleanDb(db, t)
+
+	usersRepo := repo.NewUsersRepository(db)
+	usersRepo.AddUser(t.Context(), mock.email, mock.pwd)
+	usersRepo.AddUser(t.Context(), otherMock.email, otherMock.pwd)
+
+	listsRepo := repo.NewListsRepository(db)
+	listId, err := listsRepo.AddList(t.Context(), models.List{UserEmail: mock.email, Name: "Family", Digest: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = listsRepo.InviteMember(t.Context(), mock.email, models.ListMember{ListId: listId, UserEmail: otherMock.email, Role: models.RoleViewer})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = listsRepo.AcceptInvite(t.Context(), listId, otherMock.email)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tasksRepo := repo.NewTasksRepository(db)
+	_, err = tasksRepo.AddTask(t.Context(), models.Task{Value: "buy milk", UserEmail: mock.email, ListId: &listId})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	digestOf := func(email string) []models.Task {
+		list, err := tasksRepo.GetList(t.Context(), email, models.ListOptions{DigestOnly: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return list
+	}
+
+	off := false
+	_, err = listsRepo.UpdateList(t.Context(), listId, mock.email, models.ListPatch{Digest: &off})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(digestOf(mock.email)) != 0 || len(digestOf(otherMock.email)) != 1 {
+		t.Fatal("Owner's digest opt-out affected the member")
+	}
+
+	list, err := listsRepo.UpdateList(t.Context(), listId, otherMock.email, models.ListPatch{Digest: &off})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if list.Digest || list.Role != models.RoleViewer || len(digestOf(otherMock.email)) != 0 {
+		t.Fatalf("Member could not opt out of shared list digest: %v", list)
+	}
+
+	name := "Mine"
+	_, err = listsRepo.UpdateList(t.Context(), listId, otherMock.email, models.ListPatch{Name: &name})
+	if err == nil {
+		t.Fatal("Member renamed shared list")
+	}
+}
+
+// Напоминание о задаче общего списка приходит тому, кто его установил, пока у него есть доступ к списку.
+func TestSharedList_Reminder(t *testing.T) {
+	defer cleanDb(db, t)
+
+	usersRepo := repo.NewUsersRepository(db)
+	usersRepo.AddUser(t.Context(), mock.email, mock.pwd)
+	usersRepo.AddUser(t.Context(), otherMock.email, otherMock.pwd)
+
+	listsRepo := repo.NewListsRepository(db)
+	listId, err := listsRepo.AddList(t.Context(), models.List{UserEmail: mock.email, Name: "Family", Digest: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = listsRepo.InviteMember(t.Context(), mock.email, models.ListMember{ListId: listId, UserEmail: otherMock.email, Role: models.RoleEditor})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = listsRepo.AcceptInvite(t.Context(), listId, otherMock.email)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	past := time.Now().Add(-time.Minute)
+	tasksRepo := repo.NewTasksRepository(db)
+	_, err = tasksRepo.AddTask(t.Context(), models.Task{Value: "buy milk", UserEmail: otherMock.email, ListId: &listId, RemindAt: &past})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reminders, err := tasksRepo.GetDueReminders(t.Context(), time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reminders) != 1 || reminders[0].RemindEmail != otherMock.email {
+		t.Fatalf("Wanted reminder for the editor who set it, got %v", reminders)
+	}
+
+	err = listsRepo.RemoveMember(t.Context(), listId, otherMock.email, otherMock.email)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reminders, err = tasksRepo.GetDueReminders(t.Context(), time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reminders) != 1 || reminders[0].RemindEmail != mock.email {
+		t.Fatalf("Wanted reminder for the owner after the editor left, got %v", reminders)
+	}
+}
+
+// Редактор общего списка может переставлять его задачи, а перестановка не затрагивает задачи других списков владельца.
+func TestSharedList_MoveTask(t *testing.T) {
+	defer cleanDb(db, t)
+
+	usersRepo := repo.NewUsersRepository(db)
+	usersRepo.AddUser(t.Context(), mock.email, mock.pwd)
+	usersRepo.AddUser(t.Context(), otherMock.email, otherMock.pwd)
+
+	listsRepo := repo.NewListsRepository(db)
+	listId, err := listsRepo.AddList(t.Context(), models.List{UserEmail: mock.email, Name: "Family", Digest: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = listsRepo.InviteMember(t.Context(), mock.email, models.ListMember{ListId: listId, UserEmail: otherMock.email, Role: models.RoleEditor})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = listsRepo.AcceptInvite(t.Context(), listId, otherMock.email)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tasksRepo := repo.NewTasksRepository(db)
+	ids := make(map[string]int64)
+	for _, v := range []string{"a", "private", "b"} {
+		task := models.Task{Value: v, UserEmail: mock.email}
+		if v != "private" {
+			task.ListId = &listId
+		}
+		created, err := tasksRepo.AddTask(t.Context(), task)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids[v] = created.Id
+	}
+
+	err = tasksRepo.MoveTask(t.Context(), ids["b"], otherMock.email, ids["a"], false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	list, err := tasksRepo.GetList(t.Context(), otherMock.email, models.ListOptions{ListId: &listId, Sort: models.SortPosition})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 2 || list[0].Value != "b" || list[1].Value != "a" {
+		t.Fatalf("Wanted [b a] after moving, got %v", list)
+	}
+
+	private, err := tasksRepo.GetList(t.Context(), mock.email, models.ListOptions{Sort: models.SortPosition})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, task := range private {
+		if task.Value == "private" && task.Position != 2 {
+			t.Fatalf("Moving a shared task shifted a task of another list: %v", task)
+		}
+	}
+
+	err = tasksRepo.MoveTask(t.Context(), ids["b"], otherMock.email, ids["private"], true)
+	if !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("Wanted sql.ErrNoRows when moving next to a task of another list, got %v", err)
+	}
+}
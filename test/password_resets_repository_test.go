@@ -0,0 +1,72 @@
+package test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/artemwebber1/friendly_reminder/internal/hasher"
+	repo "github.com/artemwebber1/friendly_reminder/internal/repository/postgres"
+)
+
+func TestResetPassword(t *testing.T) {
+	defer cleanDb(db, t)
+
+	usersRepo := repo.NewUsersRepository(db)
+	usersRepo.AddUser(t.Context(), mock.email, hasher.Hash(mock.pwd))
+
+	sessionsRepo := repo.NewSessionsRepository(db)
+	sessionId, err := sessionsRepo.CreateSession(t.Context(), mock.email, "refresh", time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resetsRepo := repo.NewPasswordResetsRepository(db)
+	token, err := resetsRepo.CreateResetToken(t.Context(), mock.email, time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const newPwd = "newPassword123"
+	email, err := resetsRepo.ResetPassword(t.Context(), token, hasher.Hash(newPwd))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if email != mock.email {
+		t.Fatalf("Wanted password of '%s' to be reset, got '%s'", mock.email, email)
+	}
+
+	hash, err := usersRepo.GetPasswordHash(t.Context(), mock.email)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !hasher.Verify(newPwd, hash) {
+		t.Fatal("Password was not changed")
+	}
+
+	if sessionsRepo.SessionActive(t.Context(), sessionId) {
+		t.Fatal("Session is active after password reset")
+	}
+
+	_, err = resetsRepo.ResetPassword(t.Context(), token, hasher.Hash(mock.pwd))
+	if err == nil {
+		t.Fatal("Reset token was used twice")
+	}
+}
+
+func TestResetPassword_Expired(t *testing.T) {
+	defer cleanDb(db, t)
+
+	usersRepo := repo.NewUsersRepository(db)
+	usersRepo.AddUser(t.Context(), mock.email, hasher.Hash(mock.pwd))
+
+	resetsRepo := repo.NewPasswordResetsRepository(db)
+	token, err := resetsRepo.CreateResetToken(t.Context(), mock.email, time.Now().Add(-time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = resetsRepo.ResetPassword(t.Context(), token, hasher.Hash("newPassword123"))
+	if err == nil {
+		t.Fatal("Expired reset token was accepted")
+	}
+}
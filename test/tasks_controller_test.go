@@ -2,13 +2,16 @@ package test
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"strconv"
 	"testing"
+	"time"
 
 	"github.com/artemwebber1/friendly_reminder/internal/hasher"
+	"github.com/artemwebber1/friendly_reminder/internal/models"
 	repo "github.com/artemwebber1/friendly_reminder/internal/repository/postgres"
 )
 
@@ -55,6 +58,152 @@ func TestCreateTask(t *testing.T) {
 	if resRec.Result().StatusCode != http.StatusCreated {
 		t.Fatal(statusCodesMismatch(http.StatusOK, resRec.Result().StatusCode, resRec.Body.String()))
 	}
+
+	// В ответе задача в том виде, в котором она сохранена
+	var task models.Task
+	if err = json.NewDecoder(resRec.Body).Decode(&task); err != nil {
+		t.Fatal(err)
+	}
+	if task.Id == 0 || task.Position != 1 || task.CreatedAt.IsZero() || task.UserEmail != mock.email {
+		t.Fatalf("Wanted the stored task in response, got %v", task)
+	}
+}
+
+func TestCreateTask_Notes(t *testing.T) {
+	defer cleanDb(db, t)
+
+	usersRepo := repo.NewUsersRepository(db)
+	usersRepo.AddUser(t.Context(), mock.email, hasher.Hash(mock.pwd))
+
+	notes := "Read **chapter 3**\n\n- exercises 1-5\n- [notes](https://example.com)"
+	body := bytes.NewReader(fmt.Appendf(nil, `{"value": "Do homework", "notes": %q}`, notes))
+	req, err := http.NewRequest(http.MethodPost, addr+"/tasks/new", body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req.Header.Add("Authorization", "Bearer "+getJwt(t, getUsersController(db)))
+
+	resRec := httptest.NewRecorder()
+	tasksCtrl := getTasksController(db)
+	tasksCtrl.CreateTask(resRec, req)
+
+	if resRec.Result().StatusCode != http.StatusCreated {
+		t.Fatal(statusCodesMismatch(http.StatusCreated, resRec.Result().StatusCode, resRec.Body.String()))
+	}
+
+	list, err := repo.NewTasksRepository(db).GetList(t.Context(), mock.email, models.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(list) != 1 || list[0].Value != "Do homework" || list[0].Notes != notes {
+		t.Fatalf("Notes were not saved: %v", list)
+	}
+}
+
+func TestGetList_Pagination(t *testing.T) {
+	defer cleanDb(db, t)
+
+	usersRepo := repo.NewUsersRepository(db)
+	usersRepo.AddUser(t.Context(), mock.email, hasher.Hash(mock.pwd))
+
+	tasksRepo := repo.NewTasksRepository(db)
+
+	priorities := []models.Priority{models.PriorityLow, models.PriorityHigh, models.PriorityNormal, models.PriorityHigh, models.PriorityNormal}
+	for i, p := range priorities {
+		_, err := tasksRepo.AddTask(t.Context(), models.Task{Value: strconv.Itoa(i), UserEmail: mock.email, Priority: p})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	jwt := getJwt(t, getUsersController(db))
+	tasksCtrl := getTasksController(db)
+
+	got := make([]string, 0, len(priorities))
+	cursor := ""
+	for pages := 0; ; pages++ {
+		if pages > len(priorities) {
+			t.Fatal("Pagination does not stop")
+		}
+
+		req, err := http.NewRequest(http.MethodGet, addr+"/tasks/list?sort=priority&limit=2&cursor="+cursor, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Add("Authorization", "Bearer "+jwt)
+
+		resRec := httptest.NewRecorder()
+		tasksCtrl.GetList(resRec, req)
+
+		if resRec.Result().StatusCode != http.StatusOK {
+			t.Fatal(statusCodesMismatch(http.StatusOK, resRec.Result().StatusCode, resRec.Body.String()))
+		}
+
+		var page models.TaskPage
+		if err = json.Unmarshal(resRec.Body.Bytes(), &page); err != nil {
+			t.Fatal(err)
+		}
+
+		for _, task := range page.Tasks {
+			got = append(got, task.Value)
+		}
+
+		if page.NextCursor == "" {
+			break
+		}
+		cursor = page.NextCursor
+	}
+
+	want := []string{"1", "3", "2", "4", "0"}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Fatalf("Wanted tasks %v across pages, got %v", want, got)
+	}
+}
+
+// Если атомарный пакет не выполнен, ни одна операция не применена: удачные операции получают код 424 и не возвращают задачу.
+func TestBatch_AtomicFailure(t *testing.T) {
+	defer cleanDb(db, t)
+
+	usersRepo := repo.NewUsersRepository(db)
+	usersRepo.AddUser(t.Context(), mock.email, hasher.Hash(mock.pwd))
+
+	body := bytes.NewReader([]byte(`{"atomic": true, "operations": [
+		{"op": "create", "task": {"value": "new"}},
+		{"op": "delete", "task_id": 1000000},
+		{"op": "create", "task": {"value": "never"}}
+	]}`))
+	req, err := http.NewRequest(http.MethodPost, addr+"/tasks/batch", body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Add("Authorization", "Bearer "+getJwt(t, getUsersController(db)))
+
+	resRec := httptest.NewRecorder()
+	getTasksController(db).Batch(resRec, req)
+
+	if resRec.Result().StatusCode != http.StatusConflict {
+		t.Fatal(statusCodesMismatch(http.StatusConflict, resRec.Result().StatusCode, resRec.Body.String()))
+	}
+
+	var res struct {
+		Committed bool `json:"committed"`
+		Results   []struct {
+			Status int          `json:"status"`
+			Task   *models.Task `json:"task"`
+		} `json:"results"`
+	}
+	if err = json.Unmarshal(resRec.Body.Bytes(), &res); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []int{http.StatusFailedDependency, http.StatusNotFound, http.StatusFailedDependency}
+	for i, result := range res.Results {
+		if result.Status != want[i] || result.Task != nil {
+			t.Fatalf("Wanted statuses %v without tasks, got %s", want, resRec.Body.String())
+		}
+	}
 }
 
 func TestDeleteTask(t *testing.T) {
@@ -65,10 +214,11 @@ func TestDeleteTask(t *testing.T) {
 
 	tasksRepo := repo.NewTasksRepository(db)
 
-	id, err := tasksRepo.AddTask(t.Context(), "Do homework", mock.email)
+	created, err := tasksRepo.AddTask(t.Context(), models.Task{Value: "Do homework", UserEmail: mock.email})
 	if err != nil {
 		t.Fatal(err)
 	}
+	id := created.Id
 
 	url := addr + "/tasks/del/{id}"
 	req, err := http.NewRequest(http.MethodDelete, url, nil)
@@ -88,3 +238,194 @@ func TestDeleteTask(t *testing.T) {
 		t.Fatal(statusCodesMismatch(http.StatusOK, resRec.Result().StatusCode, resRec.Body.String()))
 	}
 }
+
+func TestCompleteTask(t *testing.T) {
+	defer cleanDb(db, t)
+
+	usersRepo := repo.NewUsersRepository(db)
+	usersRepo.AddUser(t.Context(), mock.email, hasher.Hash(mock.pwd))
+
+	tasksRepo := repo.NewTasksRepository(db)
+
+	created, err := tasksRepo.AddTask(t.Context(), models.Task{Value: "Do homework", UserEmail: mock.email})
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := created.Id
+
+	req, err := http.NewRequest(http.MethodPatch, addr+"/tasks/complete/{id}", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req.SetPathValue("id", strconv.FormatInt(id, 10))
+	req.Header.Add("Authorization", "Bearer "+getJwt(t, getUsersController(db)))
+
+	resRec := httptest.NewRecorder()
+	tasksCtrl := getTasksController(db)
+	tasksCtrl.CompleteTask(resRec, req)
+
+	if resRec.Result().StatusCode != http.StatusOK {
+		t.Fatal(statusCodesMismatch(http.StatusOK, resRec.Result().StatusCode, resRec.Body.String()))
+	}
+
+	list, err := tasksRepo.GetList(t.Context(), mock.email, models.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(list) != 0 {
+		t.Fatal("Completed task is returned without include_completed")
+	}
+
+	list, err = tasksRepo.GetList(t.Context(), mock.email, models.ListOptions{IncludeCompleted: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(list) != 1 || !list[0].Completed || list[0].CompletedAt == nil {
+		t.Fatalf("Task is not marked as completed: %v", list)
+	}
+}
+
+func TestUpdateTask(t *testing.T) {
+	defer cleanDb(db, t)
+
+	usersRepo := repo.NewUsersRepository(db)
+	usersRepo.AddUser(t.Context(), mock.email, hasher.Hash(mock.pwd))
+
+	tasksRepo := repo.NewTasksRepository(db)
+
+	due := time.Now().Add(time.Hour)
+	created, err := tasksRepo.AddTask(t.Context(), models.Task{Value: "Do homework", UserEmail: mock.email, DueDate: &due})
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := created.Id
+
+	body := bytes.NewReader([]byte(`{"value": "Do homework twice", "due_date": null}`))
+	req, err := http.NewRequest(http.MethodPatch, addr+"/tasks/{id}", body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req.SetPathValue("id", strconv.FormatInt(id, 10))
+	req.Header.Add("Authorization", "Bearer "+getJwt(t, getUsersController(db)))
+
+	resRec := httptest.NewRecorder()
+	tasksCtrl := getTasksController(db)
+	tasksCtrl.UpdateTask(resRec, req)
+
+	if resRec.Result().StatusCode != http.StatusOK {
+		t.Fatal(statusCodesMismatch(http.StatusOK, resRec.Result().StatusCode, resRec.Body.String()))
+	}
+
+	list, err := tasksRepo.GetList(t.Context(), mock.email, models.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(list) != 1 || list[0].Id != id || list[0].Value != "Do homework twice" || list[0].DueDate != nil {
+		t.Fatalf("Task was not updated: %v", list)
+	}
+}
+
+func TestAddItem(t *testing.T) {
+	defer cleanDb(db, t)
+
+	usersRepo := repo.NewUsersRepository(db)
+	usersRepo.AddUser(t.Context(), mock.email, hasher.Hash(mock.pwd))
+
+	tasksRepo := repo.NewTasksRepository(db)
+
+	created, err := tasksRepo.AddTask(t.Context(), models.Task{Value: "Do homework", UserEmail: mock.email})
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := created.Id
+
+	body := bytes.NewReader([]byte(`{"value": "Math"}`))
+	req, err := http.NewRequest(http.MethodPost, addr+"/tasks/{id}/items", body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req.SetPathValue("id", strconv.FormatInt(id, 10))
+	req.Header.Add("Authorization", "Bearer "+getJwt(t, getUsersController(db)))
+
+	resRec := httptest.NewRecorder()
+	tasksCtrl := getTasksController(db)
+	tasksCtrl.AddItem(resRec, req)
+
+	if resRec.Result().StatusCode != http.StatusCreated {
+		t.Fatal(statusCodesMismatch(http.StatusCreated, resRec.Result().StatusCode, resRec.Body.String()))
+	}
+
+	items, err := tasksRepo.GetItems(t.Context(), id, mock.email)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(items) != 1 || items[0].Value != "Math" || items[0].Done {
+		t.Fatalf("Item was not added: %v", items)
+	}
+}
+
+// Здесь тестируем, что пользователь не может изменять и удалять чужие задачи - должен вернуться код 404.
+func TestTaskOperations_OtherUser(t *testing.T) {
+	defer cleanDb(db, t)
+
+	usersRepo := repo.NewUsersRepository(db)
+	usersRepo.AddUser(t.Context(), mock.email, hasher.Hash(mock.pwd))
+	usersRepo.AddUser(t.Context(), otherMock.email, hasher.Hash(otherMock.pwd))
+
+	tasksRepo := repo.NewTasksRepository(db)
+
+	created, err := tasksRepo.AddTask(t.Context(), models.Task{Value: "Do homework", UserEmail: mock.email})
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := created.Id
+
+	otherJwt := getJwtFor(t, getUsersController(db), otherMock)
+	tasksCtrl := getTasksController(db)
+
+	cases := []struct {
+		name    string
+		method  string
+		url     string
+		body    string
+		handler http.HandlerFunc
+	}{
+		{"delete", http.MethodDelete, "/tasks/del/{id}", "", tasksCtrl.DeleteTask},
+		{"update", http.MethodPatch, "/tasks/{id}", `{"value": "hacked"}`, tasksCtrl.UpdateTask},
+		{"complete", http.MethodPatch, "/tasks/complete/{id}", "", tasksCtrl.CompleteTask},
+		{"add item", http.MethodPost, "/tasks/{id}/items", `{"value": "hacked"}`, tasksCtrl.AddItem},
+	}
+
+	for _, c := range cases {
+		req, err := http.NewRequest(c.method, addr+c.url, bytes.NewReader([]byte(c.body)))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req.SetPathValue("id", strconv.FormatInt(id, 10))
+		req.Header.Add("Authorization", "Bearer "+otherJwt)
+
+		resRec := httptest.NewRecorder()
+		c.handler(resRec, req)
+
+		if resRec.Result().StatusCode != http.StatusNotFound {
+			t.Fatalf("%s: %s", c.name, statusCodesMismatch(http.StatusNotFound, resRec.Result().StatusCode, resRec.Body.String()))
+		}
+	}
+
+	list, err := tasksRepo.GetList(t.Context(), mock.email, models.ListOptions{IncludeCompleted: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(list) != 1 || list[0].Value != "Do homework" || list[0].Completed {
+		t.Fatalf("Task was changed by another user: %v", list)
+	}
+}
@@ -2,8 +2,12 @@ package test
 
 import (
 	"context"
+	"database/sql"
+	"slices"
 	"testing"
+	"time"
 
+	"github.com/artemwebber1/friendly_reminder/internal/models"
 	repo "github.com/artemwebber1/friendly_reminder/internal/repository/postgres"
 )
 
@@ -25,10 +29,10 @@ func TestGetList(t *testing.T) {
 	tasks := []string{"do homework", "smth", "##@@??"}
 
 	for _, task := range tasks {
-		itemsRepo.AddTask(t.Context(), task, email)
+		itemsRepo.AddTask(t.Context(), models.Task{Value: task, UserEmail: email})
 	}
 
-	list, err := itemsRepo.GetList(t.Context(), email)
+	list, err := itemsRepo.GetList(t.Context(), email, models.ListOptions{})
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -44,13 +48,142 @@ func TestGetList(t *testing.T) {
 	}
 }
 
+func TestGetList_DueFilter(t *testing.T) {
+	defer cleanDb(db, t)
+
+	usersRepo := repo.NewUsersRepository(db)
+	err := usersRepo.AddUser(t.Context(), mock.email, mock.pwd)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	yesterday := now.AddDate(0, 0, -1)
+	nextMonth := now.AddDate(0, 1, 0)
+
+	tasksRepo := repo.NewTasksRepository(db)
+	tasks := []models.Task{
+		{Value: "no due date", UserEmail: mock.email},
+		{Value: "next month", UserEmail: mock.email, DueDate: &nextMonth},
+		{Value: "overdue", UserEmail: mock.email, DueDate: &yesterday},
+	}
+	for _, task := range tasks {
+		_, err = tasksRepo.AddTask(t.Context(), task)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	overdue, err := tasksRepo.GetList(t.Context(), mock.email, models.ListOptions{Due: models.DueOverdue})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(overdue) != 1 || overdue[0].Value != "overdue" {
+		t.Fatalf("Wanted only overdue task, got %v", overdue)
+	}
+
+	sorted, err := tasksRepo.GetList(t.Context(), mock.email, models.ListOptions{Sort: models.SortDue})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"overdue", "next month", "no due date"}
+	for i := range sorted {
+		if sorted[i].Value != want[i] {
+			t.Fatalf("Wanted task %q at position %d, got %q", want[i], i, sorted[i].Value)
+		}
+	}
+}
+
+// Границы сегодняшнего дня вычисляются в часовом поясе пользователя, а не сервера.
+func TestGetList_DueTodayLocation(t *testing.T) {
+	defer cleanDb(db, t)
+
+	usersRepo := repo.NewUsersRepository(db)
+	err := usersRepo.AddUser(t.Context(), mock.email, mock.pwd)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	loc, err := time.LoadLocation("Pacific/Kiritimati")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now().In(loc)
+	startOfDay := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+	today := startOfDay.Add(time.Minute)
+	yesterday := startOfDay.Add(-time.Minute)
+
+	tasksRepo := repo.NewTasksRepository(db)
+	for _, task := range []models.Task{
+		{Value: "today", UserEmail: mock.email, DueDate: &today},
+		{Value: "yesterday", UserEmail: mock.email, DueDate: &yesterday},
+	} {
+		_, err = tasksRepo.AddTask(t.Context(), task)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	list, err := tasksRepo.GetList(t.Context(), mock.email, models.ListOptions{Due: models.DueToday, Location: loc})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(list) != 1 || list[0].Value != "today" {
+		t.Fatalf("Wanted only task due today in %s, got %v", loc, list)
+	}
+}
+
+func TestGetList_PriorityOrder(t *testing.T) {
+	defer cleanDb(db, t)
+
+	usersRepo := repo.NewUsersRepository(db)
+	err := usersRepo.AddUser(t.Context(), mock.email, mock.pwd)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tasksRepo := repo.NewTasksRepository(db)
+	tasks := []models.Task{
+		{Value: "low", UserEmail: mock.email, Priority: models.PriorityLow},
+		{Value: "normal 1", UserEmail: mock.email},
+		{Value: "high", UserEmail: mock.email, Priority: models.PriorityHigh},
+		{Value: "normal 2", UserEmail: mock.email},
+	}
+	for _, task := range tasks {
+		_, err = tasksRepo.AddTask(t.Context(), task)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	list, err := tasksRepo.GetList(t.Context(), mock.email, models.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"high", "normal 1", "normal 2", "low"}
+	if len(list) != len(want) {
+		t.Fatalf("Wanted %d tasks, got %d", len(want), len(list))
+	}
+
+	for i := range list {
+		if list[i].Value != want[i] {
+			t.Fatalf("Wanted task %q at position %d, got %q", want[i], i, list[i].Value)
+		}
+	}
+}
+
 // Здесь тестируем обновление списка для несуществующего пользователя - должна возникнуть ошибка FOREIGN KEY constraint failed.
 func TestAddTask_InvalidEmail(t *testing.T) {
 	defer cleanDb(db, t)
 
 	tasksRepo := repo.NewTasksRepository(db)
 
-	_, err := tasksRepo.AddTask(t.Context(), "error", "invalid@mail.com")
+	_, err := tasksRepo.AddTask(t.Context(), models.Task{Value: "error", UserEmail: "invalid@mail.com"})
 	if err == nil {
 		t.Fatal(err)
 	}
@@ -64,8 +197,628 @@ func TestAddTask_Timeout(t *testing.T) {
 	ctx, cancel := context.WithTimeout(t.Context(), 0)
 	defer cancel()
 
-	_, err := tasksRepo.AddTask(ctx, "error", "invalid@mail.com")
+	_, err := tasksRepo.AddTask(ctx, models.Task{Value: "error", UserEmail: "invalid@mail.com"})
 	if err != context.DeadlineExceeded {
 		t.Fatalf("Wanted error %s, got %s", context.DeadlineExceeded, err)
 	}
 }
+
+func TestGetDueReminders(t *testing.T) {
+	defer cleanDb(db, t)
+
+	usersRepo := repo.NewUsersRepository(db)
+	err := usersRepo.AddUser(t.Context(), mock.email, mock.pwd)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	past := time.Now().Add(-time.Minute)
+	future := time.Now().Add(time.Hour)
+
+	tasksRepo := repo.NewTasksRepository(db)
+	created, err := tasksRepo.AddTask(t.Context(), models.Task{Value: "due", UserEmail: mock.email, RemindAt: &past})
+	if err != nil {
+		t.Fatal(err)
+	}
+	dueId := created.Id
+
+	_, err = tasksRepo.AddTask(t.Context(), models.Task{Value: "not due", UserEmail: mock.email, RemindAt: &future})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reminders, err := tasksRepo.GetDueReminders(t.Context(), time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(reminders) != 1 || reminders[0].Id != dueId {
+		t.Fatalf("Wanted one due reminder with id %d, got %v", dueId, reminders)
+	}
+
+	err = tasksRepo.MarkReminderSent(t.Context(), dueId)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reminders, err = tasksRepo.GetDueReminders(t.Context(), time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(reminders) != 0 {
+		t.Fatalf("Wanted no due reminders after sending, got %v", reminders)
+	}
+}
+
+func TestMoveTask(t *testing.T) {
+	defer cleanDb(db, t)
+
+	usersRepo := repo.NewUsersRepository(db)
+	err := usersRepo.AddUser(t.Context(), mock.email, mock.pwd)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tasksRepo := repo.NewTasksRepository(db)
+	ids := make(map[string]int64)
+	for _, v := range []string{"a", "b", "c", "d"} {
+		created, err := tasksRepo.AddTask(t.Context(), models.Task{Value: v, UserEmail: mock.email})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids[v] = created.Id
+	}
+
+	moves := []struct {
+		task, anchor string
+		after        bool
+		want         []string
+	}{
+		{"d", "a", false, []string{"d", "a", "b", "c"}},
+		{"d", "b", true, []string{"a", "b", "d", "c"}},
+		{"a", "c", true, []string{"b", "d", "c", "a"}},
+	}
+
+	for _, m := range moves {
+		err = tasksRepo.MoveTask(t.Context(), ids[m.task], mock.email, ids[m.anchor], m.after)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		list, err := tasksRepo.GetList(t.Context(), mock.email, models.ListOptions{Sort: models.SortPosition})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for i := range m.want {
+			if list[i].Value != m.want[i] {
+				t.Fatalf("After moving %q: wanted %q at position %d, got %q", m.task, m.want[i], i, list[i].Value)
+			}
+		}
+	}
+}
+
+func TestTags(t *testing.T) {
+	defer cleanDb(db, t)
+
+	usersRepo := repo.NewUsersRepository(db)
+	err := usersRepo.AddUser(t.Context(), mock.email, mock.pwd)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tasksRepo := repo.NewTasksRepository(db)
+	created, err := tasksRepo.AddTask(t.Context(), models.Task{Value: "tagged", UserEmail: mock.email})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tagged := created.Id
+
+	_, err = tasksRepo.AddTask(t.Context(), models.Task{Value: "untagged", UserEmail: mock.email})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = tasksRepo.AddTags(t.Context(), tagged, mock.email, []string{"work", "urgent", "work"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	list, err := tasksRepo.GetList(t.Context(), mock.email, models.ListOptions{Tag: "work"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(list) != 1 || list[0].Id != tagged || !slices.Equal(list[0].Tags, []string{"urgent", "work"}) {
+		t.Fatalf("Wanted only tagged task with tags [urgent work], got %v", list)
+	}
+
+	err = tasksRepo.RemoveTag(t.Context(), tagged, mock.email, "work")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	list, err = tasksRepo.GetList(t.Context(), mock.email, models.ListOptions{Tag: "work"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(list) != 0 {
+		t.Fatalf("Tag was not removed: %v", list)
+	}
+
+	err = tasksRepo.AddTags(t.Context(), tagged, otherMock.email, []string{"hacked"})
+	if err != sql.ErrNoRows {
+		t.Fatalf("Wanted error %s when tagging another user's task, got %v", sql.ErrNoRows, err)
+	}
+}
+
+func TestItems(t *testing.T) {
+	defer cleanDb(db, t)
+
+	usersRepo := repo.NewUsersRepository(db)
+	err := usersRepo.AddUser(t.Context(), mock.email, mock.pwd)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tasksRepo := repo.NewTasksRepository(db)
+	created, err := tasksRepo.AddTask(t.Context(), models.Task{Value: "pack for vacation", UserEmail: mock.email})
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := created.Id
+
+	tickets, err := tasksRepo.AddItem(t.Context(), id, mock.email, "buy tickets")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = tasksRepo.AddItem(t.Context(), id, mock.email, "book hotel")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	done := true
+	_, err = tasksRepo.UpdateItem(t.Context(), id, tickets.Id, mock.email, models.TaskItemPatch{Done: &done})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	list, err := tasksRepo.GetList(t.Context(), mock.email, models.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(list) != 1 || len(list[0].Items) != 2 {
+		t.Fatalf("Wanted one task with two items, got %v", list)
+	}
+
+	items := list[0].Items
+	if items[0].Value != "buy tickets" || !items[0].Done || items[1].Value != "book hotel" || items[1].Done {
+		t.Fatalf("Items were not saved in order with their state: %v", items)
+	}
+
+	_, err = tasksRepo.AddItem(t.Context(), id, otherMock.email, "hacked")
+	if err != sql.ErrNoRows {
+		t.Fatalf("Wanted error %s when adding item to another user's task, got %v", sql.ErrNoRows, err)
+	}
+
+	err = tasksRepo.DeleteItem(t.Context(), id, tickets.Id, otherMock.email)
+	if err != sql.ErrNoRows {
+		t.Fatalf("Wanted error %s when deleting another user's item, got %v", sql.ErrNoRows, err)
+	}
+
+	err = tasksRepo.DeleteItem(t.Context(), id, tickets.Id, mock.email)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	items, err = tasksRepo.GetItems(t.Context(), id, mock.email)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(items) != 1 || items[0].Value != "book hotel" {
+		t.Fatalf("Item was not deleted: %v", items)
+	}
+}
+
+func TestSetCompleted_Recurring(t *testing.T) {
+	defer cleanDb(db, t)
+
+	usersRepo := repo.NewUsersRepository(db)
+	err := usersRepo.AddUser(t.Context(), mock.email, mock.pwd)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	due := time.Now().Add(time.Hour).Truncate(time.Second)
+	remind := due.Add(-30 * time.Minute)
+
+	tasksRepo := repo.NewTasksRepository(db)
+	created, err := tasksRepo.AddTask(t.Context(), models.Task{
+		Value:      "water plants",
+		UserEmail:  mock.email,
+		DueDate:    &due,
+		RemindAt:   &remind,
+		Recurrence: "FREQ=DAILY;INTERVAL=3",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := created.Id
+
+	next, err := tasksRepo.SetCompleted(t.Context(), id, mock.email, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if next == nil {
+		t.Fatal("Next occurrence was not created")
+	}
+
+	wantDue := due.AddDate(0, 0, 3)
+	if next.DueDate == nil || !next.DueDate.Equal(wantDue) {
+		t.Fatalf("Wanted next due date %s, got %v", wantDue, next.DueDate)
+	}
+
+	if next.RemindAt == nil || !next.RemindAt.Equal(wantDue.Add(-30*time.Minute)) {
+		t.Fatalf("Reminder of next occurrence was not shifted: %v", next.RemindAt)
+	}
+
+	list, err := tasksRepo.GetList(t.Context(), mock.email, models.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(list) != 1 || list[0].Id != next.Id || list[0].Recurrence != "FREQ=DAILY;INTERVAL=3" {
+		t.Fatalf("Wanted only next occurrence in list, got %v", list)
+	}
+}
+
+// Повторная отметка о выполнении после отмены не должна создавать ещё одно повторение.
+func TestSetCompleted_RecurringReopened(t *testing.T) {
+	defer cleanDb(db, t)
+
+	usersRepo := repo.NewUsersRepository(db)
+	err := usersRepo.AddUser(t.Context(), mock.email, mock.pwd)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tasksRepo := repo.NewTasksRepository(db)
+	created, err := tasksRepo.AddTask(t.Context(), models.Task{
+		Value:      "water plants",
+		UserEmail:  mock.email,
+		Recurrence: "FREQ=DAILY",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := created.Id
+
+	next, err := tasksRepo.SetCompleted(t.Context(), id, mock.email, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// У задачи без срока следующее повторение отсчитывается от момента выполнения
+	if next == nil || next.DueDate == nil || !next.DueDate.After(time.Now()) {
+		t.Fatalf("Wanted next occurrence with due date, got %v", next)
+	}
+
+	_, err = tasksRepo.SetCompleted(t.Context(), id, mock.email, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	again, err := tasksRepo.SetCompleted(t.Context(), id, mock.email, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if again == nil || again.Id != next.Id {
+		t.Fatalf("Wanted existing occurrence %d, got %v", next.Id, again)
+	}
+
+	list, err := tasksRepo.GetList(t.Context(), mock.email, models.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(list) != 1 || list[0].Id != next.Id {
+		t.Fatalf("Wanted only one next occurrence in list, got %v", list)
+	}
+}
+
+func TestTrash(t *testing.T) {
+	defer cleanDb(db, t)
+
+	usersRepo := repo.NewUsersRepository(db)
+	err := usersRepo.AddUser(t.Context(), mock.email, mock.pwd)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tasksRepo := repo.NewTasksRepository(db)
+	created, err := tasksRepo.AddTask(t.Context(), models.Task{Value: "first", UserEmail: mock.email})
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := created.Id
+
+	_, err = tasksRepo.AddTask(t.Context(), models.Task{Value: "second", UserEmail: mock.email})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = tasksRepo.ClearList(t.Context(), mock.email)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	list, err := tasksRepo.GetList(t.Context(), mock.email, models.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	trash, err := tasksRepo.GetTrash(t.Context(), mock.email)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(list) != 0 || len(trash) != 2 || trash[0].DeletedAt == nil {
+		t.Fatalf("Wanted empty list and 2 tasks in trash, got %v and %v", list, trash)
+	}
+
+	n, err := tasksRepo.UndoDelete(t.Context(), mock.email)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	list, err = tasksRepo.GetList(t.Context(), mock.email, models.ListOptions{Sort: models.SortPosition})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n != 2 || len(list) != 2 || list[0].Value != "first" || list[1].Value != "second" {
+		t.Fatalf("Cleared list was not restored in order: %v", list)
+	}
+
+	err = tasksRepo.DeleteTask(t.Context(), first, mock.email)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = tasksRepo.RestoreTask(t.Context(), first, otherMock.email)
+	if err != sql.ErrNoRows {
+		t.Fatalf("Wanted error %s when restoring another user's task, got %v", sql.ErrNoRows, err)
+	}
+
+	n, err = tasksRepo.PurgeTrash(t.Context(), time.Now().Add(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = tasksRepo.RestoreTask(t.Context(), first, mock.email)
+	if n != 1 || err != sql.ErrNoRows {
+		t.Fatalf("Task was not purged from trash: purged %d, restore error %v", n, err)
+	}
+}
+
+func TestSearch(t *testing.T) {
+	defer cleanDb(db, t)
+
+	usersRepo := repo.NewUsersRepository(db)
+	err := usersRepo.AddUser(t.Context(), mock.email, mock.pwd)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tasksRepo := repo.NewTasksRepository(db)
+	tasks := []models.Task{
+		{Value: "Купить молоко", UserEmail: mock.email},
+		{Value: "Позвонить маме", Notes: "Спросить про молоко для пирога", UserEmail: mock.email},
+		{Value: "Write reports", UserEmail: mock.email},
+		{Value: "Review the report draft", UserEmail: mock.email},
+	}
+
+	ids := make([]int64, len(tasks))
+	for i, task := range tasks {
+		created, err := tasksRepo.AddTask(t.Context(), task)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids[i] = created.Id
+	}
+
+	// Совпадение в тексте задачи должно идти раньше совпадения в описании, словоформы должны находиться
+	res, err := tasksRepo.Search(t.Context(), mock.email, models.SearchQuery{Text: "молока", Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if res.Total != 2 || len(res.Tasks) != 2 || res.Tasks[0].Id != ids[0] || res.Tasks[1].Id != ids[1] {
+		t.Fatalf("Wanted tasks %d and %d ranked by relevance, got %v", ids[0], ids[1], res)
+	}
+
+	res, err = tasksRepo.Search(t.Context(), mock.email, models.SearchQuery{Text: "report", Limit: 1, Offset: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if res.Total != 2 || len(res.Tasks) != 1 {
+		t.Fatalf("Wanted second page with 1 of 2 tasks, got %v", res)
+	}
+
+	res, err = tasksRepo.Search(t.Context(), otherMock.email, models.SearchQuery{Text: "report", Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if res.Total != 0 {
+		t.Fatalf("Found another user's tasks: %v", res)
+	}
+}
+
+func TestBatch(t *testing.T) {
+	defer cleanDb(db, t)
+
+	usersRepo := repo.NewUsersRepository(db)
+	usersRepo.AddUser(t.Context(), mock.email, mock.pwd)
+	usersRepo.AddUser(t.Context(), otherMock.email, otherMock.pwd)
+
+	tasksRepo := repo.NewTasksRepository(db)
+	created, err := tasksRepo.AddTask(t.Context(), models.Task{Value: "own", UserEmail: mock.email})
+	if err != nil {
+		t.Fatal(err)
+	}
+	own := created.Id
+
+	created, err = tasksRepo.AddTask(t.Context(), models.Task{Value: "foreign", UserEmail: otherMock.email})
+	if err != nil {
+		t.Fatal(err)
+	}
+	foreign := created.Id
+
+	ops := []models.BatchOperation{
+		{Op: models.BatchCreate, Task: &models.Task{Value: "new"}},
+		{Op: models.BatchComplete, TaskId: own},
+		{Op: models.BatchDelete, TaskId: foreign},
+	}
+
+	results, committed, err := tasksRepo.Batch(t.Context(), mock.email, ops, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if committed || results[2].Err != sql.ErrNoRows {
+		t.Fatalf("Wanted atomic batch to fail on another user's task, got committed = %v, results %v", committed, results)
+	}
+
+	list, err := tasksRepo.GetList(t.Context(), mock.email, models.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(list) != 1 || list[0].Id != own {
+		t.Fatalf("Atomic batch was not rolled back: %v", list)
+	}
+
+	results, committed, err = tasksRepo.Batch(t.Context(), mock.email, ops, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !committed || results[0].Err != nil || results[0].Task == nil || results[1].Err != nil || results[2].Err != sql.ErrNoRows {
+		t.Fatalf("Wanted only the last operation to fail, got committed = %v, results %v", committed, results)
+	}
+
+	if created := results[0].Task; created.UserEmail != mock.email || created.CreatedAt.IsZero() || created.Position == 0 {
+		t.Fatalf("Wanted created task as stored, got %v", created)
+	}
+
+	list, err = tasksRepo.GetList(t.Context(), mock.email, models.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(list) != 1 || list[0].Id != results[0].Task.Id {
+		t.Fatalf("Wanted only the created task in list, got %v", list)
+	}
+
+	foreignList, err := tasksRepo.GetList(t.Context(), otherMock.email, models.ListOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(foreignList) != 1 {
+		t.Fatalf("Another user's task was deleted: %v", foreignList)
+	}
+}
+
+func TestHistory(t *testing.T) {
+	defer cleanDb(db, t)
+
+	usersRepo := repo.NewUsersRepository(db)
+	err := usersRepo.AddUser(t.Context(), mock.email, mock.pwd)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tasksRepo := repo.NewTasksRepository(db)
+	created, err := tasksRepo.AddTask(t.Context(), models.Task{Value: "draft", UserEmail: mock.email})
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := created.Id
+
+	value := "final"
+	_, err = tasksRepo.UpdateTask(t.Context(), id, mock.email, models.TaskPatch{Value: &value})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	item, err := tasksRepo.AddItem(t.Context(), id, mock.email, "check spelling")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = tasksRepo.DeleteItem(t.Context(), id, item.Id, mock.email)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = tasksRepo.SetCompleted(t.Context(), id, mock.email, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = tasksRepo.DeleteTask(t.Context(), id, mock.email)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = tasksRepo.RestoreTask(t.Context(), id, mock.email)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	events, err := tasksRepo.GetHistory(t.Context(), id, mock.email)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []models.TaskEventType{
+		models.EventCreated,
+		models.EventEdited,
+		models.EventEdited,
+		models.EventEdited,
+		models.EventCompleted,
+		models.EventDeleted,
+		models.EventRestored,
+	}
+
+	got := make([]models.TaskEventType, 0, len(events))
+	for _, e := range events {
+		got = append(got, e.Type)
+		if e.UserEmail != mock.email {
+			t.Fatalf("Wanted event author %s, got %s", mock.email, e.UserEmail)
+		}
+	}
+
+	if !slices.Equal(got, want) {
+		t.Fatalf("Wanted events %v, got %v", want, got)
+	}
+
+	if string(events[1].Details) != `{"value": "final"}` {
+		t.Fatalf("Wanted edited fields in event details, got %s", events[1].Details)
+	}
+
+	_, err = tasksRepo.GetHistory(t.Context(), id, otherMock.email)
+	if err != sql.ErrNoRows {
+		t.Fatalf("Wanted error %s when reading another user's history, got %v", sql.ErrNoRows, err)
+	}
+}
@@ -3,6 +3,7 @@ package test
 import (
 	"bytes"
 	"database/sql"
+	"encoding/json"
 	"fmt"
 	"log"
 	"net/http"
@@ -13,6 +14,7 @@ import (
 	"github.com/artemwebber1/friendly_reminder/internal/config"
 	"github.com/artemwebber1/friendly_reminder/internal/controller"
 	repo "github.com/artemwebber1/friendly_reminder/internal/repository/postgres"
+	"github.com/artemwebber1/friendly_reminder/pkg/authorization"
 	"github.com/artemwebber1/friendly_reminder/pkg/email"
 	"github.com/joho/godotenv"
 	_ "github.com/lib/pq" // postgres driver
@@ -28,6 +30,12 @@ var mock = m{
 	pwd:   "password4321",
 }
 
+// otherMock - второй пользователь, для проверки доступа к чужим данным.
+var otherMock = m{
+	email: "other@mail.com",
+	pwd:   "password1234",
+}
+
 var cfg *config.Config
 var dbUsed config.DbConfig
 var addr string
@@ -46,18 +54,34 @@ func init() {
 	if err != nil {
 		panic(err)
 	}
+
+	authorization.SetSessionChecker(repo.NewSessionsRepository(db))
 }
 
 func cleanDb(db *sql.DB, t *testing.T) {
-	_, err := db.Exec("DELETE FROM tasks; DELETE FROM users; DELETE FROM unverified_users;")
+	_, err := db.Exec("DELETE FROM tasks; DELETE FROM lists; DELETE FROM users; DELETE FROM unverified_users;")
 	if err != nil {
 		t.Fatal(err)
 	}
 }
 
 func getJwt(t *testing.T, usersCtrl *controller.UsersController) string {
+	return getJwtFor(t, usersCtrl, mock)
+}
+
+func getJwtFor(t *testing.T, usersCtrl *controller.UsersController, user m) string {
+	return loginAs(t, usersCtrl, user).AccessToken
+}
+
+// tokens - ответ на вход в систему и обновление токенов.
+type tokens struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+func loginAs(t *testing.T, usersCtrl *controller.UsersController, user m) tokens {
 	resRec := httptest.NewRecorder()
-	body := fmt.Appendf(nil, "{\"email\": \"%s\", \"password\": \"%s\"}", mock.email, mock.pwd)
+	body := fmt.Appendf(nil, "{\"email\": \"%s\", \"password\": \"%s\"}", user.email, user.pwd)
 	req, err := http.NewRequest(http.MethodPost, addr+"/login", bytes.NewReader(body))
 	if err != nil {
 		t.Fatal(err)
@@ -67,7 +91,11 @@ func getJwt(t *testing.T, usersCtrl *controller.UsersController) string {
 		t.Fatal(statusCodesMismatch(http.StatusOK, resRec.Result().StatusCode, resRec.Body.String()))
 	}
 
-	return resRec.Body.String()
+	var tok tokens
+	if err = json.Unmarshal(resRec.Body.Bytes(), &tok); err != nil {
+		t.Fatal(err)
+	}
+	return tok
 }
 
 func statusCodesMismatch(wanted, got int, body string) string {
@@ -77,8 +105,11 @@ func statusCodesMismatch(wanted, got int, body string) string {
 func getUsersController(db *sql.DB) *controller.UsersController {
 	ur := repo.NewUsersRepository(db)
 	uur := repo.NewUnverifiedUsersRepository(db)
+	sr := repo.NewSessionsRepository(db)
+	prr := repo.NewPasswordResetsRepository(db)
+	ecr := repo.NewEmailChangesRepository(db)
 	sender := getEmailSender(cfg.EmailOptions.Host, cfg.EmailOptions.Port)
-	return controller.NewUsersController(ur, uur, sender, cfg)
+	return controller.NewUsersController(ur, uur, sr, prr, ecr, sender, cfg)
 }
 
 func getTasksController(db *sql.DB) *controller.TasksController {
@@ -87,6 +118,13 @@ func getTasksController(db *sql.DB) *controller.TasksController {
 	return controller.NewTasksController(tr, ur, cfg)
 }
 
+func getListsController(db *sql.DB) *controller.ListsController {
+	lr := repo.NewListsRepository(db)
+	ur := repo.NewUsersRepository(db)
+	sender := getEmailSender(cfg.EmailOptions.Host, cfg.EmailOptions.Port)
+	return controller.NewListsController(lr, ur, sender, cfg)
+}
+
 func getEmailSender(emailHost, emailPort string) email.Sender {
 	return email.NewSender(
 		os.Getenv("EMAIL"),
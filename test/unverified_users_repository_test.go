@@ -1,8 +1,13 @@
 package test
 
 import (
+	"database/sql"
+	"errors"
 	"testing"
+	"time"
 
+	"github.com/artemwebber1/friendly_reminder/internal/hasher"
+	"github.com/artemwebber1/friendly_reminder/internal/models"
 	repo "github.com/artemwebber1/friendly_reminder/internal/repository/postgres"
 )
 
@@ -43,3 +48,52 @@ func TestGetUserByToken(t *testing.T) {
 		t.Fatal("Email and password mismatch")
 	}
 }
+
+// Регистрация не завершается, если почту заняли, пока она ждала подтверждения.
+func TestConfirmUser_Taken(t *testing.T) {
+	defer cleanDb(db, t)
+
+	tokRepo := repo.NewUnverifiedUsersRepository(db)
+	tok, err := tokRepo.CreateToken(mock.email, hasher.Hash(mock.pwd))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	usersRepo := repo.NewUsersRepository(db)
+	usersRepo.AddUser(t.Context(), mock.email, hasher.Hash(mock.pwd))
+
+	_, err = tokRepo.ConfirmUser(t.Context(), tok)
+	if !errors.Is(err, models.ErrEmailTaken) {
+		t.Fatalf("Wanted models.ErrEmailTaken, got %v", err)
+	}
+}
+
+// Смена почты на адрес, регистрация на который не подтверждена, отменяет эту регистрацию.
+func TestConfirmUser_EmailChanged(t *testing.T) {
+	defer cleanDb(db, t)
+
+	tokRepo := repo.NewUnverifiedUsersRepository(db)
+	tok, err := tokRepo.CreateToken(otherMock.email, hasher.Hash(otherMock.pwd))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	usersRepo := repo.NewUsersRepository(db)
+	usersRepo.AddUser(t.Context(), mock.email, hasher.Hash(mock.pwd))
+
+	changesRepo := repo.NewEmailChangesRepository(db)
+	changeTok, err := changesRepo.CreateEmailChange(t.Context(), mock.email, otherMock.email, time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, _, err = changesRepo.ChangeEmail(t.Context(), changeTok)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = tokRepo.ConfirmUser(t.Context(), tok)
+	if !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("Sign-up was not cancelled by email change, got %v", err)
+	}
+}
@@ -2,6 +2,8 @@ package test
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
@@ -9,6 +11,7 @@ import (
 
 	"github.com/artemwebber1/friendly_reminder/internal/hasher"
 	repo "github.com/artemwebber1/friendly_reminder/internal/repository/postgres"
+	"github.com/artemwebber1/friendly_reminder/pkg/authorization"
 )
 
 func TestSendConfirmEmailLink(t *testing.T) {
@@ -132,6 +135,30 @@ func TestLogin(t *testing.T) {
 	}
 }
 
+// Здесь тестируем вход пользователя со старым хэшем пароля SHA-256 - хэш должен замениться на новый.
+func TestLogin_LegacyHash(t *testing.T) {
+	defer cleanDb(db, t)
+
+	sum := sha256.Sum256([]byte(mock.pwd))
+	usersRepo := repo.NewUsersRepository(db)
+	err := usersRepo.AddUser(t.Context(), mock.email, string(sum[:]))
+	if err != nil {
+		t.Fatalf("Failed to create user: %s", err)
+	}
+
+	usersCtrl := getUsersController(db)
+	getJwt(t, usersCtrl)
+
+	hash, err := usersRepo.GetPasswordHash(t.Context(), mock.email)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if hasher.NeedsRehash(hash) || !hasher.Verify(mock.pwd, hash) {
+		t.Fatalf("Password was not rehashed, got %q", hash)
+	}
+}
+
 func TestLogin_UserDoesntExist(t *testing.T) {
 	defer cleanDb(db, t)
 
@@ -148,3 +175,271 @@ func TestLogin_UserDoesntExist(t *testing.T) {
 		t.Fatal(statusCodesMismatch(http.StatusForbidden, resRec.Result().StatusCode, resRec.Body.String()))
 	}
 }
+
+func TestSetDigestSchedule(t *testing.T) {
+	defer cleanDb(db, t)
+
+	usersCtrl := getUsersController(db)
+
+	usersRepo := repo.NewUsersRepository(db)
+	usersRepo.AddUser(t.Context(), mock.email, hasher.Hash(mock.pwd))
+
+	tok := getJwt(t, usersCtrl)
+
+	body := []byte(`{"digest_schedule": "30 8 * * 1-5", "time_zone": "Europe/Moscow"}`)
+	req, err := http.NewRequest(http.MethodPatch, addr+"/users/schedule", bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Add("Authorization", "Bearer "+tok)
+
+	resRec := httptest.NewRecorder()
+	usersCtrl.SetDigestSchedule(resRec, req)
+	if resRec.Result().StatusCode != http.StatusOK {
+		t.Fatal(statusCodesMismatch(http.StatusOK, resRec.Result().StatusCode, resRec.Body.String()))
+	}
+
+	u, err := usersRepo.GetByEmail(t.Context(), mock.email)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if u.DigestSchedule != "30 8 * * 1-5" || u.TimeZone != "Europe/Moscow" {
+		t.Fatalf("Schedule was not saved: got '%s' '%s'", u.DigestSchedule, u.TimeZone)
+	}
+}
+
+func TestSetDigestSchedule_Invalid(t *testing.T) {
+	defer cleanDb(db, t)
+
+	usersCtrl := getUsersController(db)
+
+	usersRepo := repo.NewUsersRepository(db)
+	usersRepo.AddUser(t.Context(), mock.email, hasher.Hash(mock.pwd))
+
+	tok := getJwt(t, usersCtrl)
+
+	body := []byte(`{"digest_schedule": "30 8 * * 1-5", "time_zone": "Mars/Olympus"}`)
+	req, err := http.NewRequest(http.MethodPatch, addr+"/users/schedule", bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Add("Authorization", "Bearer "+tok)
+
+	resRec := httptest.NewRecorder()
+	usersCtrl.SetDigestSchedule(resRec, req)
+	if resRec.Result().StatusCode != http.StatusBadRequest {
+		t.Fatal(statusCodesMismatch(http.StatusBadRequest, resRec.Result().StatusCode, resRec.Body.String()))
+	}
+}
+
+// Здесь тестируем обновление токенов: старый refresh токен после обновления не действует.
+func TestRefresh(t *testing.T) {
+	defer cleanDb(db, t)
+
+	usersRepo := repo.NewUsersRepository(db)
+	usersRepo.AddUser(t.Context(), mock.email, hasher.Hash(mock.pwd))
+
+	usersCtrl := getUsersController(db)
+	tok := loginAs(t, usersCtrl, mock)
+
+	refresh := func(refreshToken string) *httptest.ResponseRecorder {
+		body := fmt.Appendf(nil, "{\"refresh_token\": \"%s\"}", refreshToken)
+		req, err := http.NewRequest(http.MethodPost, addr+"/users/refresh", bytes.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		resRec := httptest.NewRecorder()
+		usersCtrl.Refresh(resRec, req)
+		return resRec
+	}
+
+	resRec := refresh(tok.RefreshToken)
+	if resRec.Result().StatusCode != http.StatusOK {
+		t.Fatal(statusCodesMismatch(http.StatusOK, resRec.Result().StatusCode, resRec.Body.String()))
+	}
+
+	var newTok tokens
+	if err := json.Unmarshal(resRec.Body.Bytes(), &newTok); err != nil {
+		t.Fatal(err)
+	}
+	if newTok.AccessToken == "" || newTok.RefreshToken == tok.RefreshToken {
+		t.Fatalf("Refresh token was not rotated: %s", resRec.Body.String())
+	}
+
+	resRec = refresh(tok.RefreshToken)
+	if resRec.Result().StatusCode != http.StatusForbidden {
+		t.Fatal(statusCodesMismatch(http.StatusForbidden, resRec.Result().StatusCode, resRec.Body.String()))
+	}
+
+	// Повторное использование заменённого токена завершает сессию
+	resRec = refresh(newTok.RefreshToken)
+	if resRec.Result().StatusCode != http.StatusForbidden {
+		t.Fatal(statusCodesMismatch(http.StatusForbidden, resRec.Result().StatusCode, resRec.Body.String()))
+	}
+
+	// Access токен завершённой сессии тоже отклоняется, хотя его срок действия не истёк
+	req, err := http.NewRequest(http.MethodGet, addr+"/tasks/list", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Add("Authorization", "Bearer "+newTok.AccessToken)
+
+	resRec = httptest.NewRecorder()
+	authorization.Middleware(getTasksController(db).GetList)(resRec, req)
+	if resRec.Result().StatusCode != http.StatusUnauthorized {
+		t.Fatal(statusCodesMismatch(http.StatusUnauthorized, resRec.Result().StatusCode, resRec.Body.String()))
+	}
+}
+
+// Здесь тестируем выход из системы: токены завершённой сессии отклоняются.
+func TestLogout(t *testing.T) {
+	defer cleanDb(db, t)
+
+	usersRepo := repo.NewUsersRepository(db)
+	usersRepo.AddUser(t.Context(), mock.email, hasher.Hash(mock.pwd))
+
+	usersCtrl := getUsersController(db)
+	tok := loginAs(t, usersCtrl, mock)
+
+	logout := authorization.Middleware(usersCtrl.Logout)
+	newRequest := func() *http.Request {
+		req, err := http.NewRequest(http.MethodPost, addr+"/users/logout", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Add("Authorization", "Bearer "+tok.AccessToken)
+		return req
+	}
+
+	resRec := httptest.NewRecorder()
+	logout(resRec, newRequest())
+	if resRec.Result().StatusCode != http.StatusNoContent {
+		t.Fatal(statusCodesMismatch(http.StatusNoContent, resRec.Result().StatusCode, resRec.Body.String()))
+	}
+
+	resRec = httptest.NewRecorder()
+	logout(resRec, newRequest())
+	if resRec.Result().StatusCode != http.StatusUnauthorized {
+		t.Fatal(statusCodesMismatch(http.StatusUnauthorized, resRec.Result().StatusCode, resRec.Body.String()))
+	}
+
+	body := fmt.Appendf(nil, "{\"refresh_token\": \"%s\"}", tok.RefreshToken)
+	req, err := http.NewRequest(http.MethodPost, addr+"/users/refresh", bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resRec = httptest.NewRecorder()
+	usersCtrl.Refresh(resRec, req)
+	if resRec.Result().StatusCode != http.StatusForbidden {
+		t.Fatal(statusCodesMismatch(http.StatusForbidden, resRec.Result().StatusCode, resRec.Body.String()))
+	}
+}
+
+func TestChangePassword(t *testing.T) {
+	defer cleanDb(db, t)
+
+	usersRepo := repo.NewUsersRepository(db)
+	usersRepo.AddUser(t.Context(), mock.email, hasher.Hash(mock.pwd))
+
+	usersCtrl := getUsersController(db)
+	tok := getJwt(t, usersCtrl)
+
+	changePassword := func(oldPwd, newPwd string) *httptest.ResponseRecorder {
+		body := fmt.Appendf(nil, "{\"old_password\": \"%s\", \"new_password\": \"%s\"}", oldPwd, newPwd)
+		req, err := http.NewRequest(http.MethodPatch, addr+"/users/me/password", bytes.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Add("Authorization", "Bearer "+tok)
+
+		resRec := httptest.NewRecorder()
+		usersCtrl.ChangePassword(resRec, req)
+		return resRec
+	}
+
+	const newPwd = "newPassword123"
+	resRec := changePassword("wrong", newPwd)
+	if resRec.Result().StatusCode != http.StatusForbidden {
+		t.Fatal(statusCodesMismatch(http.StatusForbidden, resRec.Result().StatusCode, resRec.Body.String()))
+	}
+
+	resRec = changePassword(mock.pwd, newPwd)
+	if resRec.Result().StatusCode != http.StatusOK {
+		t.Fatal(statusCodesMismatch(http.StatusOK, resRec.Result().StatusCode, resRec.Body.String()))
+	}
+
+	getJwtFor(t, usersCtrl, m{email: mock.email, pwd: newPwd})
+}
+
+func TestDeleteMe(t *testing.T) {
+	defer cleanDb(db, t)
+
+	usersRepo := repo.NewUsersRepository(db)
+	usersRepo.AddUser(t.Context(), mock.email, hasher.Hash(mock.pwd))
+
+	usersCtrl := getUsersController(db)
+	tok := getJwt(t, usersCtrl)
+
+	deleteMe := func(pwd string) *httptest.ResponseRecorder {
+		body := fmt.Appendf(nil, "{\"password\": \"%s\"}", pwd)
+		req, err := http.NewRequest(http.MethodDelete, addr+"/users/me", bytes.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Add("Authorization", "Bearer "+tok)
+
+		resRec := httptest.NewRecorder()
+		usersCtrl.DeleteMe(resRec, req)
+		return resRec
+	}
+
+	resRec := deleteMe("wrong")
+	if resRec.Result().StatusCode != http.StatusForbidden {
+		t.Fatal(statusCodesMismatch(http.StatusForbidden, resRec.Result().StatusCode, resRec.Body.String()))
+	}
+
+	if !usersRepo.EmailExists(t.Context(), mock.email) {
+		t.Fatal("User was deleted with wrong password")
+	}
+
+	resRec = deleteMe(mock.pwd)
+	if resRec.Result().StatusCode != http.StatusNoContent {
+		t.Fatal(statusCodesMismatch(http.StatusNoContent, resRec.Result().StatusCode, resRec.Body.String()))
+	}
+
+	if usersRepo.EmailExists(t.Context(), mock.email) {
+		t.Fatal("User was not deleted")
+	}
+}
+
+// Нельзя сменить почту на адрес, регистрация на который ещё не подтверждена.
+func TestRequestEmailChange_PendingSignUp(t *testing.T) {
+	defer cleanDb(db, t)
+
+	usersRepo := repo.NewUsersRepository(db)
+	usersRepo.AddUser(t.Context(), mock.email, hasher.Hash(mock.pwd))
+
+	_, err := repo.NewUnverifiedUsersRepository(db).CreateToken(otherMock.email, hasher.Hash(otherMock.pwd))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	usersCtrl := getUsersController(db)
+
+	body := fmt.Appendf(nil, "{\"email\": \"%s\", \"password\": \"%s\"}", otherMock.email, mock.pwd)
+	req, err := http.NewRequest(http.MethodPost, addr+"/users/me/email", bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Add("Authorization", "Bearer "+getJwt(t, usersCtrl))
+
+	resRec := httptest.NewRecorder()
+	usersCtrl.RequestEmailChange(resRec, req)
+
+	if resRec.Result().StatusCode != http.StatusForbidden {
+		t.Fatal(statusCodesMismatch(http.StatusForbidden, resRec.Result().StatusCode, resRec.Body.String()))
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"slices"
 	"testing"
 
+	"github.com/artemwebber1/friendly_reminder/internal/models"
 	repo "github.com/artemwebber1/friendly_reminder/internal/repository/postgres"
 )
 
@@ -62,3 +63,69 @@ func TestGetByEmail(t *testing.T) {
 		t.Error("mismatch email or password")
 	}
 }
+
+// Здесь тестируем удаление пользователя: вместе с ним должны удалиться все его задачи и списки.
+func TestDeleteUser(t *testing.T) {
+	defer cleanDb(db, t)
+
+	usersRepo := repo.NewUsersRepository(db)
+	usersRepo.AddUser(t.Context(), mock.email, mock.pwd)
+
+	listsRepo := repo.NewListsRepository(db)
+	listId, err := listsRepo.AddList(t.Context(), models.List{UserEmail: mock.email, Name: "Work", Digest: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tasksRepo := repo.NewTasksRepository(db)
+	var taskId int64
+	for _, task := range []models.Task{
+		{Value: "default", UserEmail: mock.email},
+		{Value: "work", UserEmail: mock.email, ListId: &listId},
+	} {
+		created, err := tasksRepo.AddTask(t.Context(), task)
+		if err != nil {
+			t.Fatal(err)
+		}
+		taskId = created.Id
+	}
+
+	_, err = repo.NewCommentsRepository(db).AddComment(t.Context(), models.Comment{TaskId: taskId, UserEmail: mock.email, Text: "soon"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	export, err := usersRepo.ExportData(t.Context(), mock.email)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(export.Lists) != 1 || len(export.Tasks) != 2 || len(export.Comments) != 1 {
+		t.Fatalf("Wanted 1 list, 2 tasks and 1 comment in export, got %v", export)
+	}
+
+	err = usersRepo.DeleteUser(t.Context(), mock.email)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if usersRepo.EmailExists(t.Context(), mock.email) {
+		t.Fatal("User was not deleted")
+	}
+
+	var n int
+	err = db.QueryRow("SELECT COUNT(*) FROM tasks WHERE user_email = $1", mock.email).Scan(&n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 0 {
+		t.Fatalf("%d tasks of deleted user were left", n)
+	}
+
+	err = db.QueryRow("SELECT COUNT(*) FROM task_events WHERE user_email = $1", mock.email).Scan(&n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 0 {
+		t.Fatalf("%d history events of deleted user were left", n)
+	}
+}